@@ -0,0 +1,175 @@
+package x402go
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/berhberhberh/x402go/internal/parse"
+)
+
+// Amount is a non-negative token amount in the token's smallest unit.
+// The zero value is zero.
+type Amount struct {
+	value *big.Int
+}
+
+// NewAmount creates an Amount from a number of base units
+func NewAmount(units *big.Int) (Amount, error) {
+	if units == nil || units.Sign() < 0 {
+		return Amount{}, fmt.Errorf("amount must be non-negative")
+	}
+	return Amount{value: new(big.Int).Set(units)}, nil
+}
+
+// ParseAmount parses an amount of base units given as a decimal or 0x-prefixed hex integer
+func ParseAmount(s string) (Amount, error) {
+	value, err := parse.Uint(strings.TrimSpace(s), "amount")
+	if err != nil {
+		return Amount{}, err
+	}
+	return Amount{value: value}, nil
+}
+
+// ParseDecimalAmount parses an amount written in whole tokens, e.g. "1.50", for a token
+// with the given number of decimals. It fails if s is more precise than the token.
+func ParseDecimalAmount(s string, decimals int) (Amount, error) {
+	if decimals < 0 {
+		return Amount{}, fmt.Errorf("invalid decimals %d", decimals)
+	}
+
+	whole, frac, _ := strings.Cut(strings.TrimSpace(s), ".")
+	if whole == "" && frac == "" {
+		return Amount{}, fmt.Errorf("invalid amount %q", s)
+	}
+	if whole == "" {
+		whole = "0"
+	}
+
+	// Digits beyond the token's precision are only allowed if they are zero
+	if len(frac) > decimals {
+		if strings.Trim(frac[decimals:], "0") != "" {
+			return Amount{}, fmt.Errorf("amount %q has more than %d decimals", s, decimals)
+		}
+		frac = frac[:decimals]
+	}
+	frac += strings.Repeat("0", decimals-len(frac))
+
+	value, ok := new(big.Int).SetString(whole+frac, 10)
+	if !ok || value.Sign() < 0 || strings.ContainsAny(whole+frac, "+-") {
+		return Amount{}, fmt.Errorf("invalid amount %q", s)
+	}
+	return Amount{value: value}, nil
+}
+
+// BigInt returns the amount in base units
+func (a Amount) BigInt() *big.Int {
+	if a.value == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(a.value)
+}
+
+// String returns the amount in base units as a decimal integer
+func (a Amount) String() string {
+	return a.BigInt().String()
+}
+
+// Format returns the amount in whole tokens for a token with the given number of
+// decimals, without trailing zeros, e.g. "1.5" for 1500000 with 6 decimals
+func (a Amount) Format(decimals int) string {
+	digits := a.String()
+	if decimals <= 0 {
+		return digits
+	}
+
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+	}
+	whole, frac := digits[:len(digits)-decimals], strings.TrimRight(digits[len(digits)-decimals:], "0")
+	if frac == "" {
+		return whole
+	}
+	return whole + "." + frac
+}
+
+// Cmp compares two amounts, returning -1, 0 or +1
+func (a Amount) Cmp(b Amount) int {
+	return a.BigInt().Cmp(b.BigInt())
+}
+
+// Add returns the sum of two amounts
+func (a Amount) Add(b Amount) Amount {
+	return Amount{value: new(big.Int).Add(a.BigInt(), b.BigInt())}
+}
+
+// IsZero reports whether the amount is zero
+func (a Amount) IsZero() bool {
+	return a.value == nil || a.value.Sign() == 0
+}
+
+// MarshalText encodes the amount as a decimal integer of base units
+func (a Amount) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalText decodes an amount of base units
+func (a *Amount) UnmarshalText(text []byte) error {
+	amount, err := ParseAmount(string(text))
+	if err != nil {
+		return err
+	}
+	*a = amount
+	return nil
+}
+
+// OverpaymentPolicy decides whether a paid amount satisfies the required amount
+type OverpaymentPolicy func(paid, required Amount) bool
+
+var (
+	// AllowOverpayment accepts any amount at or above the required amount
+	AllowOverpayment OverpaymentPolicy = func(paid, required Amount) bool {
+		return paid.Cmp(required) >= 0
+	}
+
+	// RejectOverpayment accepts exactly the required amount only
+	RejectOverpayment OverpaymentPolicy = func(paid, required Amount) bool {
+		return paid.Cmp(required) == 0
+	}
+)
+
+// OverpaymentUpTo accepts amounts exceeding the required amount by at most max
+func OverpaymentUpTo(max Amount) OverpaymentPolicy {
+	return func(paid, required Amount) bool {
+		limit := new(big.Int).Add(required.BigInt(), max.BigInt())
+		return paid.Cmp(required) >= 0 && paid.BigInt().Cmp(limit) <= 0
+	}
+}
+
+// OverpaymentUpToBasisPoints accepts amounts exceeding the required amount by at most
+// bps hundredths of a percent of it, e.g. 100 for 1%
+func OverpaymentUpToBasisPoints(bps int64) OverpaymentPolicy {
+	return func(paid, required Amount) bool {
+		max := new(big.Int).Mul(required.BigInt(), big.NewInt(bps))
+		max.Quo(max, big.NewInt(10000))
+		return OverpaymentUpTo(Amount{value: max})(paid, required)
+	}
+}
+
+// checkAmount parses a paid and a required amount of base units and applies policy,
+// accepting any overpayment when policy is nil
+func checkAmount(policy OverpaymentPolicy, paid, required string) (bool, error) {
+	paidAmount, err := ParseAmount(paid)
+	if err != nil {
+		return false, err
+	}
+	requiredAmount, err := ParseAmount(required)
+	if err != nil {
+		return false, err
+	}
+
+	if policy == nil {
+		policy = AllowOverpayment
+	}
+	return policy(paidAmount, requiredAmount), nil
+}
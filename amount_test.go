@@ -0,0 +1,128 @@
+package x402go
+
+import "testing"
+
+func TestParseDecimalAmount(t *testing.T) {
+	tests := []struct {
+		in       string
+		decimals int
+		want     string
+		wantErr  bool
+	}{
+		{"1.50", 6, "1500000", false},
+		{"1", 6, "1000000", false},
+		{"0.000001", 6, "1", false},
+		{".5", 6, "500000", false},
+		{"1.", 6, "1000000", false},
+		{" 2.5 ", 6, "2500000", false},
+		{"1.5000000", 6, "1500000", false},
+		{"7", 0, "7", false},
+		{"1.0000001", 6, "", true},
+		{"0.5", 0, "", true},
+		{"-1.5", 6, "", true},
+		{"-0", 6, "", true},
+		{"+1", 6, "", true},
+		{"", 6, "", true},
+		{".", 6, "", true},
+		{"   ", 6, "", true},
+		{"1.2.3", 6, "", true},
+		{"1e6", 6, "", true},
+		{"0x10", 6, "", true},
+		{"1", -1, "", true},
+	}
+	for _, tt := range tests {
+		amount, err := ParseDecimalAmount(tt.in, tt.decimals)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseDecimalAmount(%q, %d) err = %v, want error %v", tt.in, tt.decimals, err, tt.wantErr)
+			continue
+		}
+		if err == nil && amount.String() != tt.want {
+			t.Errorf("ParseDecimalAmount(%q, %d) = %s, want %s", tt.in, tt.decimals, amount, tt.want)
+		}
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"1000", "1000", false},
+		{"0x3e8", "1000", false},
+		{" 1000 ", "1000", false},
+		{"0", "0", false},
+		{"", "", true},
+		{"-1", "", true},
+		{"1.5", "", true},
+	}
+	for _, tt := range tests {
+		amount, err := ParseAmount(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseAmount(%q) err = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err == nil && amount.String() != tt.want {
+			t.Errorf("ParseAmount(%q) = %s, want %s", tt.in, amount, tt.want)
+		}
+	}
+}
+
+func TestAmountFormat(t *testing.T) {
+	tests := []struct {
+		units    string
+		decimals int
+		want     string
+	}{
+		{"1500000", 6, "1.5"},
+		{"1000000", 6, "1"},
+		{"1", 6, "0.000001"},
+		{"0", 6, "0"},
+		{"42", 0, "42"},
+	}
+	for _, tt := range tests {
+		amount, _ := ParseAmount(tt.units)
+		if got := amount.Format(tt.decimals); got != tt.want {
+			t.Errorf("%s.Format(%d) = %q, want %q", tt.units, tt.decimals, got, tt.want)
+		}
+	}
+}
+
+func TestOverpaymentPolicies(t *testing.T) {
+	ten, _ := ParseAmount("10")
+	tests := []struct {
+		name   string
+		policy OverpaymentPolicy
+		paid   string
+		want   bool
+	}{
+		{"default exact", nil, "1000", true},
+		{"default over", nil, "5000", true},
+		{"default under", nil, "999", false},
+		{"reject exact", RejectOverpayment, "1000", true},
+		{"reject over", RejectOverpayment, "1001", false},
+		{"reject under", RejectOverpayment, "999", false},
+		{"up to 10 exact", OverpaymentUpTo(ten), "1000", true},
+		{"up to 10 at limit", OverpaymentUpTo(ten), "1010", true},
+		{"up to 10 over limit", OverpaymentUpTo(ten), "1011", false},
+		{"up to 10 under", OverpaymentUpTo(ten), "999", false},
+		{"1% at limit", OverpaymentUpToBasisPoints(100), "1010", true},
+		{"1% over limit", OverpaymentUpToBasisPoints(100), "1011", false},
+	}
+	for _, tt := range tests {
+		covered, err := checkAmount(tt.policy, tt.paid, "1000")
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if covered != tt.want {
+			t.Errorf("%s: covered = %v, want %v", tt.name, covered, tt.want)
+		}
+	}
+
+	if _, err := checkAmount(nil, "", "1000"); err == nil {
+		t.Error("empty paid amount accepted")
+	}
+	if _, err := checkAmount(nil, "-1000", "1000"); err == nil {
+		t.Error("negative paid amount accepted")
+	}
+}
@@ -0,0 +1,140 @@
+package x402go
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+)
+
+// ErrInsufficientBalance is returned when a debit exceeds the account's balance
+var ErrInsufficientBalance = errors.New("insufficient balance")
+
+// BalanceStore keeps prepaid balances, in token base units, keyed by account
+type BalanceStore interface {
+	// Credit adds amount to an account and returns the new balance
+	Credit(account string, amount Amount) (Amount, error)
+
+	// Debit takes amount from an account and returns the new balance. It fails with
+	// ErrInsufficientBalance, leaving the balance unchanged, if the balance is too low.
+	Debit(account string, amount Amount) (Amount, error)
+
+	// Balance returns the balance of an account, zero for unknown accounts
+	Balance(account string) (Amount, error)
+}
+
+// balanceSet is the shared bookkeeping behind the BalanceStore implementations
+type balanceSet map[string]Amount
+
+func (s balanceSet) credit(account string, amount Amount) Amount {
+	balance := Amount{value: new(big.Int).Add(s[account].BigInt(), amount.BigInt())}
+	s[account] = balance
+	return balance
+}
+
+func (s balanceSet) debit(account string, amount Amount) (Amount, error) {
+	current := s[account]
+	if current.Cmp(amount) < 0 {
+		return current, ErrInsufficientBalance
+	}
+	balance := Amount{value: new(big.Int).Sub(current.BigInt(), amount.BigInt())}
+	s[account] = balance
+	return balance, nil
+}
+
+// MemoryBalanceStore is an in-memory BalanceStore
+type MemoryBalanceStore struct {
+	mu       sync.Mutex
+	balances balanceSet
+}
+
+// NewMemoryBalanceStore creates an empty in-memory balance store
+func NewMemoryBalanceStore() *MemoryBalanceStore {
+	return &MemoryBalanceStore{
+		balances: make(balanceSet),
+	}
+}
+
+// Credit implements BalanceStore
+func (s *MemoryBalanceStore) Credit(account string, amount Amount) (Amount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.balances.credit(account, amount), nil
+}
+
+// Debit implements BalanceStore
+func (s *MemoryBalanceStore) Debit(account string, amount Amount) (Amount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.balances.debit(account, amount)
+}
+
+// Balance implements BalanceStore
+func (s *MemoryBalanceStore) Balance(account string) (Amount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.balances[account], nil
+}
+
+// FileBalanceStore is a BalanceStore persisted as a JSON file, so balances survive
+// process restarts. A change that cannot be saved is not applied.
+type FileBalanceStore struct {
+	mu       sync.Mutex
+	file     jsonFile
+	balances balanceSet
+}
+
+// NewFileBalanceStore opens the balance store at path. The file is created on the first
+// change if it does not exist.
+func NewFileBalanceStore(path string) (*FileBalanceStore, error) {
+	s := &FileBalanceStore{
+		file:     jsonFile{path: path, name: "balance store"},
+		balances: make(balanceSet),
+	}
+	if err := s.file.load(&s.balances); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Credit implements BalanceStore
+func (s *FileBalanceStore) Credit(account string, amount Amount) (Amount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.balances[account]
+	balance := s.balances.credit(account, amount)
+	if err := s.file.commit(s.balances, s.restore(account, previous)); err != nil {
+		return previous, err
+	}
+	return balance, nil
+}
+
+// Debit implements BalanceStore
+func (s *FileBalanceStore) Debit(account string, amount Amount) (Amount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.balances[account]
+	balance, err := s.balances.debit(account, amount)
+	if err != nil {
+		return balance, err
+	}
+	if err := s.file.commit(s.balances, s.restore(account, previous)); err != nil {
+		return previous, err
+	}
+	return balance, nil
+}
+
+// Balance implements BalanceStore
+func (s *FileBalanceStore) Balance(account string) (Amount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.balances[account], nil
+}
+
+// restore returns an undo resetting an account to its previous balance
+func (s *FileBalanceStore) restore(account string, previous Amount) func() {
+	return func() {
+		s.balances[account] = previous
+	}
+}
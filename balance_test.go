@@ -0,0 +1,83 @@
+package x402go
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileBalanceStore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "store")
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "balances.json")
+	store, err := NewFileBalanceStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name    string
+		credit  bool
+		amount  string
+		want    string
+		wantErr error
+	}{
+		{"credit", true, "100", "100", nil},
+		{"debit", false, "30", "70", nil},
+		{"overdraw", false, "71", "70", ErrInsufficientBalance},
+		{"debit rest", false, "70", "0", nil},
+	}
+	for _, step := range steps {
+		var balance Amount
+		if step.credit {
+			balance, err = store.Credit("a", mustAmount(t, step.amount))
+		} else {
+			balance, err = store.Debit("a", mustAmount(t, step.amount))
+		}
+		if !errors.Is(err, step.wantErr) {
+			t.Fatalf("%s: err = %v, want %v", step.name, err, step.wantErr)
+		}
+		if balance.String() != step.want {
+			t.Fatalf("%s: balance %s, want %s", step.name, balance, step.want)
+		}
+	}
+
+	// Changes that cannot be saved are not applied
+	if _, err := store.Credit("a", mustAmount(t, "5")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Credit("a", mustAmount(t, "10")); err == nil {
+		t.Fatal("Credit() succeeded without saving")
+	}
+	if _, err := store.Debit("a", mustAmount(t, "5")); err == nil {
+		t.Fatal("Debit() succeeded without saving")
+	}
+	if balance, _ := store.Balance("a"); balance.String() != "5" {
+		t.Fatalf("balance after failed saves %s, want 5", balance)
+	}
+}
+
+func TestFileBalanceStoreReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "balances.json")
+	store, err := NewFileBalanceStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Credit("a", mustAmount(t, "42")); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := NewFileBalanceStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance, _ := reopened.Balance("a"); balance.String() != "42" {
+		t.Fatalf("balance after reopening %s, want 42", balance)
+	}
+}
@@ -1,11 +1,14 @@
 package x402go
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"sync"
 )
 
 // Client is an HTTP client that automatically handles x402 payments
@@ -16,14 +19,38 @@ type Client struct {
 	// It should return a Payment object with the transaction details
 	PaymentHandler func(requirements *PaymentRequirements) (*Payment, error)
 
-	// MaxRetries is the maximum number of payment retry attempts
+	// PaymentHandlerContext is like PaymentHandler but receives the request's context.
+	// It takes precedence over PaymentHandler when set.
+	PaymentHandlerContext func(ctx context.Context, requirements *PaymentRequirements) (*Payment, error)
+
+	// SelectRequirements chooses which of the server's payment options to pay
+	// (optional, defaults to SelectFirst)
+	SelectRequirements RequirementsSelector
+
+	// Tokens lists the tokens the client pays with; options in other tokens are refused
+	// (optional, defaults to DefaultTokens)
+	Tokens *TokenRegistry
+
+	// SpendPolicy decides whether the chosen option may be paid before the payment
+	// handler runs (optional, every payment is allowed without one)
+	SpendPolicy SpendPolicy
+
+	// MaxRetries is the maximum number of times a paid request is retried after a
+	// retryable failure: a stale quote is paid again, a pending payment resent
 	MaxRetries int
+
+	// Credentials caches the session tokens, access passes and settlement receipts servers
+	// issue after payments (optional, created on first use). Cached sessions and passes
+	// are presented on later requests they cover.
+	Credentials *CredentialCache
+
+	credentialsOnce sync.Once
 }
 
 // NewClient creates a new x402 client
 func NewClient() *Client {
 	return &Client{
-		httpClient: &http.Client{},
+		httpClient: newHTTPClient(),
 		MaxRetries: 1,
 	}
 }
@@ -31,26 +58,121 @@ func NewClient() *Client {
 // NewClientWithHandler creates a client with a custom payment handler
 func NewClientWithHandler(handler func(*PaymentRequirements) (*Payment, error)) *Client {
 	return &Client{
-		httpClient:     &http.Client{},
+		httpClient:     newHTTPClient(),
 		PaymentHandler: handler,
 		MaxRetries:     1,
 	}
 }
 
-// Do executes an HTTP request and handles 402 payment requirements
+// NewClientWithContextHandler creates a client with a context-aware payment handler
+func NewClientWithContextHandler(handler func(context.Context, *PaymentRequirements) (*Payment, error)) *Client {
+	return &Client{
+		httpClient:            newHTTPClient(),
+		PaymentHandlerContext: handler,
+		MaxRetries:            1,
+	}
+}
+
+// NewClientWithSigner creates a client that pays with signer, as NewSignerPaymentHandler
+// does without a transfer backend
+func NewClientWithSigner(signer Signer) *Client {
+	return NewClientWithContextHandler(NewSignerPaymentHandler(signer, nil))
+}
+
+// newHTTPClient returns the HTTP client a Client sends requests with
+func newHTTPClient() *http.Client {
+	return &http.Client{CheckRedirect: stripCredentialsOnRedirect}
+}
+
+// stripCredentialsOnRedirect follows redirects as http.Client does by default, but drops
+// the session, pass and payment presented with a request once a redirect leaves the
+// origin they were presented to
+func stripCredentialsOnRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if originOf(req.URL) != originOf(via[0].URL) {
+		for _, header := range []string{HeaderPaymentSession, HeaderPaymentPass, HeaderPayment, HeaderPaymentResponse} {
+			req.Header.Del(header)
+		}
+	}
+	return nil
+}
+
+// Do executes an HTTP request and handles 402 payment requirements. Session tokens of
+// prepaid balances and access pass tokens are cached per origin and presented on later
+// requests they cover; payment is only made again when the server answers 402.
 func (c *Client) Do(req *http.Request) (*http.Response, error) {
-	resp, err := c.httpClient.Do(req)
+	return c.do(req, c.httpClient.Do)
+}
+
+// do sends a request with send, paying for it if the server answers 402
+func (c *Client) do(req *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	credentials := c.credentials()
+	for _, attach := range []struct {
+		kind   CredentialKind
+		header string
+	}{
+		{CredentialSession, HeaderPaymentSession},
+		{CredentialPass, HeaderPaymentPass},
+	} {
+		if credential, ok := credentials.Lookup(attach.kind, req.URL); ok && req.Header.Get(attach.header) == "" {
+			req = req.Clone(req.Context())
+			req.Header.Set(attach.header, credential.Token)
+		}
+	}
+
+	resp, err := send(req)
 	if err != nil {
 		return nil, err
 	}
 
 	// If not 402, return response as-is
 	if resp.StatusCode != http.StatusPaymentRequired {
+		credentials.storeResponse(req.URL, resp)
 		return resp, nil
 	}
 
+	// The session and pass, if any, no longer cover requests
+	origin := originOf(req.URL)
+	credentials.Forget(CredentialSession, origin, req.Header.Get(HeaderPaymentSession))
+	credentials.Forget(CredentialPass, origin, req.Header.Get(HeaderPaymentPass))
+
 	// Handle payment requirement
-	return c.handlePaymentRequired(req, resp)
+	resp, err = c.handlePaymentRequired(req, resp, send)
+	if err != nil {
+		return nil, err
+	}
+	credentials.storeResponse(req.URL, resp)
+	return resp, nil
+}
+
+// credentials returns the credential cache, creating it on first use
+func (c *Client) credentials() *CredentialCache {
+	c.credentialsOnce.Do(func() {
+		if c.Credentials == nil {
+			c.Credentials = NewCredentialCache()
+		}
+	})
+	return c.Credentials
+}
+
+// Receipt returns the settlement receipt of the last payment for a URL, if the server
+// issued one
+func (c *Client) Receipt(rawURL string) (*SettleResponse, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, false
+	}
+	credential, ok := c.credentials().Lookup(CredentialReceipt, u)
+	if !ok {
+		return nil, false
+	}
+	receipt, err := ParseReceipt(credential.Token)
+	if err != nil {
+		return nil, false
+	}
+	return receipt, true
 }
 
 // Get performs a GET request
@@ -62,7 +184,8 @@ func (c *Client) Get(url string) (*http.Response, error) {
 	return c.Do(req)
 }
 
-// Post performs a POST request
+// Post performs a POST request. Paying for it replays the body, so body must be a
+// *bytes.Buffer, *bytes.Reader or *strings.Reader.
 func (c *Client) Post(url, contentType string, body io.Reader) (*http.Response, error) {
 	req, err := http.NewRequest("POST", url, body)
 	if err != nil {
@@ -72,69 +195,240 @@ func (c *Client) Post(url, contentType string, body io.Reader) (*http.Response,
 	return c.Do(req)
 }
 
-// handlePaymentRequired processes a 402 response and retries with payment
-func (c *Client) handlePaymentRequired(originalReq *http.Request, paymentResp *http.Response) (*http.Response, error) {
+// handlePaymentRequired pays for a request answered with a 402 response and sends it
+// again with the payment, using send. Retryable failures are retried up to MaxRetries
+// times: rejections of a stale quote are paid again against a fresh quote, payments
+// pending confirmation are resent after backing off. Other rejections fail with a
+// *PaymentRejectedError.
+func (c *Client) handlePaymentRequired(originalReq *http.Request, paymentResp *http.Response, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	ctx := originalReq.Context()
+	payment, format, err := c.payFor(originalReq, paymentResp)
+	if err != nil {
+		return nil, err
+	}
+
+	backoff := initialRetryBackoff
+	for retries := 0; ; retries++ {
+		resp, err := sendPayment(originalReq, payment, format, send)
+		if err != nil {
+			return nil, err
+		}
+
+		outcome, err := classifyPaidResponse(resp)
+		if outcome == paidAccepted {
+			return resp, nil
+		}
+		if outcome == paidFatal || retries >= c.MaxRetries {
+			resp.Body.Close()
+			return nil, err
+		}
+
+		switch outcome {
+		case paidPending:
+			// Resend the same payment once the server has had time to see it confirmed
+			resp.Body.Close()
+			if err := sleepContext(ctx, retryDelay(resp, backoff)); err != nil {
+				return nil, err
+			}
+			backoff *= 2
+
+		case paidRequote:
+			// Pay again against a fresh quote, from the rejection if it carries one
+			if !hasPaymentRequirements(resp) {
+				resp.Body.Close()
+				resp, err = requestQuote(originalReq, send)
+				if err != nil {
+					return nil, err
+				}
+				if resp.StatusCode != http.StatusPaymentRequired {
+					return resp, nil
+				}
+			}
+			payment, format, err = c.payFor(originalReq, resp)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// payFor chooses and pays one of the options of a 402 response, closing its body
+func (c *Client) payFor(originalReq *http.Request, paymentResp *http.Response) (*Payment, WireFormat, error) {
 	defer paymentResp.Body.Close()
 
-	// Extract payment requirements from header
-	paymentHeader := paymentResp.Header.Get(HeaderPayment)
-	if paymentHeader == "" {
-		return nil, fmt.Errorf("402 response missing %s header", HeaderPayment)
+	// Extract payment options from the legacy header or the v1 body
+	options, format, err := parsePaymentRequired(paymentResp)
+	if err != nil {
+		return nil, format, err
 	}
 
-	// Parse payment requirements
-	var requirements PaymentRequirements
-	if err := json.Unmarshal([]byte(paymentHeader), &requirements); err != nil {
-		return nil, fmt.Errorf("failed to parse payment requirements: %w", err)
+	// Only options that are safe to pay are considered
+	var payable []*PaymentRequirements
+	var invalid error
+	for _, option := range options {
+		if err := CheckPayable(option, c.Tokens); err != nil {
+			if invalid == nil {
+				invalid = err
+			}
+			continue
+		}
+		payable = append(payable, option)
+	}
+	if len(payable) == 0 {
+		return nil, format, invalid
+	}
+
+	// Choose the option to pay
+	selectRequirements := c.SelectRequirements
+	if selectRequirements == nil {
+		selectRequirements = SelectFirst
+	}
+	requirements, err := selectRequirements(payable)
+	if err != nil {
+		return nil, format, fmt.Errorf("failed to select payment option: %w", err)
 	}
 
 	// Check if we have a payment handler
-	if c.PaymentHandler == nil {
-		return nil, fmt.Errorf("payment required but no payment handler configured")
+	if c.PaymentHandler == nil && c.PaymentHandlerContext == nil {
+		return nil, format, fmt.Errorf("payment required but no payment handler configured")
+	}
+
+	// Check the payment against the spending policy, which records it against budgets
+	ctx := originalReq.Context()
+	spend := &Spend{Host: originalReq.URL.Host, Requirements: requirements}
+	if c.SpendPolicy != nil {
+		if err := c.SpendPolicy.Authorize(ctx, spend); err != nil {
+			return nil, format, fmt.Errorf("payment not authorized: %w", err)
+		}
 	}
 
 	// Call payment handler to make payment
-	payment, err := c.PaymentHandler(&requirements)
+	payment, err := c.pay(ctx, requirements)
 	if err != nil {
-		return nil, fmt.Errorf("payment handler failed: %w", err)
+		if c.SpendPolicy != nil {
+			c.SpendPolicy.Release(ctx, spend)
+		}
+		return nil, format, fmt.Errorf("payment handler failed: %w", err)
 	}
 
-	// Convert payment to JSON
-	paymentJSON, err := payment.ToJSON()
-	if err != nil {
-		return nil, fmt.Errorf("failed to encode payment: %w", err)
+	// Echo the server's signed quote so it can verify the terms statelessly
+	if payment.Quote == "" {
+		payment.Quote = QuoteToken(requirements)
 	}
+	return payment, format, nil
+}
 
+// sendPayment sends the original request again with a payment attached
+func sendPayment(originalReq *http.Request, payment *Payment, format WireFormat, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
 	// Clone the original request
 	retryReq, err := cloneRequest(originalReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to clone request: %w", err)
 	}
 
-	// Add payment response header
-	retryReq.Header.Set(HeaderPaymentResponse, paymentJSON)
+	// Attach the payment in the format the server spoke, instead of the exhausted session
+	// or pass
+	retryReq.Header.Del(HeaderPaymentSession)
+	retryReq.Header.Del(HeaderPaymentPass)
+	if err := setPaymentHeader(retryReq, payment, format); err != nil {
+		return nil, fmt.Errorf("failed to encode payment: %w", err)
+	}
 
 	// Retry request with payment
-	return c.httpClient.Do(retryReq)
+	return send(retryReq)
 }
 
-// cloneRequest creates a copy of an HTTP request
-func cloneRequest(req *http.Request) (*http.Request, error) {
-	clone := req.Clone(req.Context())
+// requestQuote sends the original request again without payment to obtain a fresh quote
+func requestQuote(originalReq *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	req, err := cloneRequest(originalReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to clone request: %w", err)
+	}
+	req.Header.Del(HeaderPaymentSession)
+	req.Header.Del(HeaderPaymentPass)
+	return send(req)
+}
+
+// pay runs the configured payment handler, preferring the context-aware one
+func (c *Client) pay(ctx context.Context, requirements *PaymentRequirements) (*Payment, error) {
+	if c.PaymentHandlerContext != nil {
+		return c.PaymentHandlerContext(ctx, requirements)
+	}
+	return c.PaymentHandler(requirements)
+}
+
+// parsePaymentRequired extracts the payment options from a 402 response. Legacy servers
+// send the primary option in the X-Payment header and may list all options in the body;
+// v1 servers send them in the accepts array of the JSON body.
+func parsePaymentRequired(resp *http.Response) ([]*PaymentRequirements, WireFormat, error) {
+	if header := resp.Header.Get(HeaderPayment); header != "" {
+		var requirements PaymentRequirements
+		if err := json.Unmarshal([]byte(header), &requirements); err != nil {
+			return nil, WireFormatLegacy, fmt.Errorf("failed to parse payment requirements: %w", err)
+		}
+
+		var body struct {
+			Accepts []*PaymentRequirements `json:"accepts"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err == nil && len(body.Accepts) > 0 {
+			return body.Accepts, WireFormatLegacy, nil
+		}
+		return []*PaymentRequirements{&requirements}, WireFormatLegacy, nil
+	}
+
+	var body PaymentRequiredResponseV1
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, WireFormatV1, fmt.Errorf("402 response carries no payment requirements: %w", err)
+	}
+	if body.X402Version != X402Version {
+		return nil, WireFormatV1, fmt.Errorf("unsupported x402Version %d", body.X402Version)
+	}
+	if len(body.Accepts) == 0 {
+		return nil, WireFormatV1, fmt.Errorf("402 response offers no payment options")
+	}
+
+	options := make([]*PaymentRequirements, len(body.Accepts))
+	for i := range body.Accepts {
+		options[i] = RequirementsFromV1(&body.Accepts[i])
+	}
+	return options, WireFormatV1, nil
+}
 
-	// If original request had a body, we need to copy it
-	if req.Body != nil {
-		bodyBytes, err := io.ReadAll(req.Body)
+// setPaymentHeader attaches a payment to a request in the given wire format
+func setPaymentHeader(req *http.Request, payment *Payment, format WireFormat) error {
+	if format == WireFormatLegacy {
+		paymentJSON, err := payment.ToJSON()
 		if err != nil {
-			return nil, err
+			return err
 		}
+		req.Header.Set(HeaderPaymentResponse, paymentJSON)
+		return nil
+	}
 
-		// Restore original body
-		req.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+	header, err := EncodeHeaderV1(payment.ToV1())
+	if err != nil {
+		return err
+	}
+	req.Header.Set(HeaderPayment, header)
+	return nil
+}
 
-		// Set clone body
-		clone.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+// cloneRequest creates a copy of an HTTP request that has already been sent, with a
+// fresh body from GetBody. Bodies that cannot be recreated cannot be paid for, since the
+// first attempt consumed them.
+func cloneRequest(req *http.Request) (*http.Request, error) {
+	clone := req.Clone(req.Context())
+	if req.Body == nil || req.Body == http.NoBody {
+		return clone, nil
+	}
+	if req.GetBody == nil {
+		return nil, fmt.Errorf("request body cannot be replayed; set GetBody to pay for requests with a body")
 	}
 
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	clone.Body = body
 	return clone, nil
 }
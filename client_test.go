@@ -0,0 +1,62 @@
+package x402go
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestClientRedirectCredentials(t *testing.T) {
+	var seen http.Header
+	record := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.Header.Clone()
+		w.Write([]byte("ok"))
+	})
+
+	other := httptest.NewServer(record)
+	defer other.Close()
+
+	mux := http.NewServeMux()
+	mux.Handle("/target", record)
+	mux.HandleFunc("/same", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/target", http.StatusFound)
+	})
+	mux.HandleFunc("/cross", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, other.URL+"/target", http.StatusFound)
+	})
+	origin := httptest.NewServer(mux)
+	defer origin.Close()
+
+	tests := []struct {
+		name     string
+		path     string
+		wantSent bool
+	}{
+		{"same origin", "/same", true},
+		{"cross origin", "/cross", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient()
+			u, _ := url.Parse(origin.URL)
+			client.Credentials = NewCredentialCache()
+			client.Credentials.Store(&Credential{Kind: CredentialSession, Origin: originOf(u), Scope: "/", Token: "session-token"})
+			client.Credentials.Store(&Credential{Kind: CredentialPass, Origin: originOf(u), Scope: "/", Token: "pass-token"})
+
+			seen = nil
+			resp, err := client.Get(origin.URL + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			for _, header := range []string{HeaderPaymentSession, HeaderPaymentPass} {
+				if sent := seen.Get(header) != ""; sent != tt.wantSent {
+					t.Errorf("%s sent after redirect: %v, want %v", header, sent, tt.wantSent)
+				}
+			}
+		})
+	}
+}
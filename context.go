@@ -0,0 +1,67 @@
+package x402go
+
+import (
+	"context"
+)
+
+// ContextPaymentVerifier is a PaymentVerifier that honours cancellation and deadlines
+type ContextPaymentVerifier interface {
+	PaymentVerifier
+
+	// VerifyContext verifies a payment within ctx
+	VerifyContext(ctx context.Context, payment *Payment, requirements *PaymentRequirements) (bool, error)
+}
+
+// ContextFacilitator is a Facilitator that honours cancellation and deadlines
+type ContextFacilitator interface {
+	Facilitator
+
+	// VerifyContext checks a payment within ctx
+	VerifyContext(ctx context.Context, req *VerifyRequest) (*VerifyResponse, error)
+
+	// SettleContext settles a payment within ctx
+	SettleContext(ctx context.Context, req *SettleRequest) (*SettleResponse, error)
+}
+
+// AsContextVerifier returns v as a ContextPaymentVerifier. Verifiers without context
+// support are wrapped and run to completion regardless of ctx.
+func AsContextVerifier(v PaymentVerifier) ContextPaymentVerifier {
+	if cv, ok := v.(ContextPaymentVerifier); ok {
+		return cv
+	}
+	return contextVerifier{v}
+}
+
+// AsContextFacilitator returns f as a ContextFacilitator. Facilitators without context
+// support are wrapped and run to completion regardless of ctx.
+func AsContextFacilitator(f Facilitator) ContextFacilitator {
+	if cf, ok := f.(ContextFacilitator); ok {
+		return cf
+	}
+	return contextFacilitator{f}
+}
+
+// contextVerifier adapts a PaymentVerifier to ContextPaymentVerifier
+type contextVerifier struct {
+	PaymentVerifier
+}
+
+// VerifyContext implements ContextPaymentVerifier
+func (v contextVerifier) VerifyContext(ctx context.Context, payment *Payment, requirements *PaymentRequirements) (bool, error) {
+	return v.Verify(payment, requirements)
+}
+
+// contextFacilitator adapts a Facilitator to ContextFacilitator
+type contextFacilitator struct {
+	Facilitator
+}
+
+// VerifyContext implements ContextFacilitator
+func (f contextFacilitator) VerifyContext(ctx context.Context, req *VerifyRequest) (*VerifyResponse, error) {
+	return f.Verify(req)
+}
+
+// SettleContext implements ContextFacilitator
+func (f contextFacilitator) SettleContext(ctx context.Context, req *SettleRequest) (*SettleResponse, error) {
+	return f.Settle(req)
+}
@@ -0,0 +1,181 @@
+package x402go
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// CredentialKind identifies what a server issued after a payment
+type CredentialKind string
+
+const (
+	// CredentialSession is the session token of a prepaid balance, good for every
+	// resource of its origin
+	CredentialSession CredentialKind = "session"
+
+	// CredentialPass is an access pass token, good for the resources under its scope
+	CredentialPass CredentialKind = "pass"
+
+	// CredentialReceipt is the settlement receipt of a payment for a single resource. It
+	// is kept for the record and never presented.
+	CredentialReceipt CredentialKind = "receipt"
+)
+
+// Credential is a token a server issued after a payment
+type Credential struct {
+	Kind CredentialKind
+
+	// Origin is the scheme and host that issued the credential, e.g. "https://api.example.com"
+	Origin string
+
+	// Scope is the path prefix the credential covers, the paid resource's path for receipts
+	Scope string
+
+	// Token is the header value as issued
+	Token string
+
+	// Expiry is when the credential expires, zero if it does not say
+	Expiry time.Time
+}
+
+// valid reports whether the credential has not expired at now
+func (c *Credential) valid(now time.Time) bool {
+	return c.Expiry.IsZero() || now.Before(c.Expiry)
+}
+
+// CredentialCache keeps the credentials servers issue after payments, keyed by origin
+// and resource, so a Client presents them instead of paying again. It is safe for
+// concurrent use and may be shared by several clients.
+type CredentialCache struct {
+	mu          sync.Mutex
+	credentials map[string][]*Credential
+}
+
+// NewCredentialCache creates an empty credential cache
+func NewCredentialCache() *CredentialCache {
+	return &CredentialCache{
+		credentials: make(map[string][]*Credential),
+	}
+}
+
+// Lookup returns the unexpired credential of a kind that covers a URL, if any. Expired
+// credentials of the URL's origin are dropped.
+func (c *CredentialCache) Lookup(kind CredentialKind, u *url.URL) (*Credential, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	origin := originOf(u)
+	now := time.Now()
+	var found *Credential
+	var kept []*Credential
+	for _, credential := range c.credentials[origin] {
+		if !credential.valid(now) {
+			continue
+		}
+		kept = append(kept, credential)
+		if found == nil && credential.Kind == kind && credentialCovers(credential, u.Path) {
+			found = credential
+		}
+	}
+	if len(kept) == 0 {
+		delete(c.credentials, origin)
+	} else {
+		c.credentials[origin] = kept
+	}
+	return found, found != nil
+}
+
+// Store adds a credential. A session or receipt replaces the one of the same scope.
+func (c *CredentialCache) Store(credential *Credential) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	credentials := c.credentials[credential.Origin]
+	if credential.Kind != CredentialPass {
+		for i, existing := range credentials {
+			if existing.Kind == credential.Kind && existing.Scope == credential.Scope {
+				credentials = append(credentials[:i:i], credentials[i+1:]...)
+				break
+			}
+		}
+	}
+	c.credentials[credential.Origin] = append(credentials, credential)
+}
+
+// Forget drops the credential of a kind issued by an origin with the given token
+func (c *CredentialCache) Forget(kind CredentialKind, origin, token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	credentials := c.credentials[origin]
+	for i, credential := range credentials {
+		if credential.Kind == kind && credential.Token == token {
+			c.credentials[origin] = append(credentials[:i:i], credentials[i+1:]...)
+			return
+		}
+	}
+}
+
+// credentialCovers reports whether a credential applies to a request for path
+func credentialCovers(credential *Credential, path string) bool {
+	if credential.Kind == CredentialReceipt {
+		return path == credential.Scope
+	}
+	return inScope(path, credential.Scope)
+}
+
+// storeResponse caches the credentials issued in the response to a request for u. After
+// redirects, they are cached for the URL that issued them.
+func (c *CredentialCache) storeResponse(u *url.URL, resp *http.Response) {
+	if resp.Request != nil && resp.Request.URL != nil {
+		u = resp.Request.URL
+	}
+	origin := originOf(u)
+
+	if token := resp.Header.Get(HeaderPaymentSession); token != "" {
+		credential := &Credential{Kind: CredentialSession, Origin: origin, Scope: "/", Token: token}
+		var session Session
+		if decodeToken(token, &session) == nil {
+			credential.Expiry = time.Unix(session.Expiry, 0)
+		}
+		c.Store(credential)
+	}
+
+	if token := resp.Header.Get(HeaderPaymentPass); token != "" {
+		var pass Pass
+		if decodeToken(token, &pass) == nil {
+			c.Store(&Credential{Kind: CredentialPass, Origin: origin, Scope: pass.Scope, Token: token, Expiry: time.Unix(pass.Expiry, 0)})
+		}
+	}
+
+	if token := resp.Header.Get(HeaderPaymentResponse); token != "" && isSuccessStatus(resp.StatusCode) {
+		c.Store(&Credential{Kind: CredentialReceipt, Origin: origin, Scope: u.Path, Token: token})
+	}
+}
+
+// originOf returns the scheme and host of a URL
+func originOf(u *url.URL) string {
+	return u.Scheme + "://" + u.Host
+}
+
+// ParseReceipt decodes a settlement receipt from the X-Payment-Response header, in
+// either wire format
+func ParseReceipt(header string) (*SettleResponse, error) {
+	var legacy SettleResponse
+	if err := json.Unmarshal([]byte(header), &legacy); err == nil {
+		return &legacy, nil
+	}
+
+	var v1 SettlementResponseV1
+	if err := DecodeHeaderV1(header, &v1); err != nil {
+		return nil, err
+	}
+	return &SettleResponse{
+		Settled: v1.Success,
+		TxHash:  v1.Transaction,
+		Error:   v1.ErrorReason,
+	}, nil
+}
@@ -0,0 +1,194 @@
+package x402go
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultSessionTTL is how long session tokens are valid unless configured otherwise
+const defaultSessionTTL = 15 * time.Minute
+
+// CreditConfig switches the middleware to deposit mode: a settled payment credits the
+// payer's balance with the amount paid, and every request, including the paying one,
+// is debited Cost. Deposit mode requires a settlement mode other than SettleNone. The response to a deposit carries a session token in the
+// X-Payment-Session header; requests presenting it are served from the balance without
+// a new payment until the token expires or the balance runs out.
+type CreditConfig struct {
+	// Balances stores prepaid balances (optional, defaults to an in-memory store)
+	Balances BalanceStore
+
+	// Cost is debited from the balance for every request, in base units of the deposited token
+	Cost Amount
+
+	// SessionKey signs session tokens. It must be a secret of at least 16 bytes shared by
+	// every server instance that accepts the sessions.
+	SessionKey []byte
+
+	// SessionTTL is how long a session token is valid (default: 15 minutes)
+	SessionTTL time.Duration
+}
+
+// Session identifies the prepaid balance a request is served from
+type Session struct {
+	// Chain, Token and Sender identify the deposits the balance was credited with
+	Chain  string `json:"chain"`
+	Token  string `json:"token"`
+	Sender string `json:"sender"`
+
+	// Expiry is when the session token expires (Unix timestamp)
+	Expiry int64 `json:"expiry"`
+}
+
+// Account returns the balance account of the session. Deposits by the same sender in
+// the same token share an account.
+func (s *Session) Account() string {
+	return ChainForNetwork(s.Chain) + "/" + strings.ToLower(s.Token) + "/" + strings.ToLower(s.Sender)
+}
+
+// init validates the configuration and sets defaults
+func (c *CreditConfig) init() {
+	if len(c.SessionKey) < minTokenKeyLength {
+		panic(fmt.Sprintf("credit session key must be at least %d bytes", minTokenKeyLength))
+	}
+	if c.Cost.IsZero() {
+		panic("credit cost must be positive")
+	}
+	if c.Balances == nil {
+		c.Balances = NewMemoryBalanceStore()
+	}
+	if c.SessionTTL == 0 {
+		c.SessionTTL = defaultSessionTTL
+	}
+}
+
+// readSession returns the valid session presented with a request, or nil
+func (c *CreditConfig) readSession(r *http.Request) *Session {
+	token := r.Header.Get(HeaderPaymentSession)
+	if token == "" {
+		return nil
+	}
+
+	var session Session
+	if err := verifyToken(c.SessionKey, tokenSession, token, &session); err != nil {
+		return nil
+	}
+	if time.Now().Unix() >= session.Expiry {
+		return nil
+	}
+	return &session
+}
+
+// serveFromSession serves a request from the balance of a session. It reports false,
+// without writing a response, if the balance cannot cover the request.
+func serveFromSession(w http.ResponseWriter, r *http.Request, config *MiddlewareConfig, session *Session, next http.Handler) bool {
+	credits := config.Credits
+	account := session.Account()
+
+	balance, err := credits.Balances.Debit(account, credits.Cost)
+	if errors.Is(err, ErrInsufficientBalance) {
+		return false
+	}
+	if err != nil {
+		http.Error(w, "Failed to debit balance", http.StatusInternalServerError)
+		return true
+	}
+
+	paymentCtx := &PaymentContext{
+		Payment: Payment{
+			Chain:  session.Chain,
+			Token:  session.Token,
+			Sender: session.Sender,
+		},
+		Verified:   true,
+		VerifiedAt: time.Now(),
+		Session:    session,
+		Balance:    &balance,
+	}
+	serveDebited(w, r, config, paymentCtx, next)
+	return true
+}
+
+// serveDeposit settles a verified payment, credits it to the payer's balance and serves
+// the paying request from the new balance
+func serveDeposit(w http.ResponseWriter, r *http.Request, config *MiddlewareConfig, pc *PaymentContext, requirements *PaymentRequirements, next http.Handler) {
+	credits := config.Credits
+	payment := &pc.Payment
+
+	// Deposits are settled up front, whatever the settlement mode, so credit is only
+	// granted for funds that arrived
+	resp, err := settlePayment(r.Context(), config, payment, requirements, "")
+	if err != nil {
+		settlementFailed(w, config, payment, resp, err)
+		return
+	}
+	pc.Settlement = resp
+	writeSettlementHeader(w, config, payment, resp)
+
+	deposit, err := depositAmount(payment, requirements)
+	if err != nil {
+		http.Error(w, "Invalid deposit amount", http.StatusInternalServerError)
+		return
+	}
+
+	session := &Session{
+		Chain:  requirements.Chain,
+		Token:  requirements.Token,
+		Sender: payment.Sender,
+		Expiry: time.Now().Add(credits.SessionTTL).Unix(),
+	}
+	if _, err := credits.Balances.Credit(session.Account(), deposit); err != nil {
+		http.Error(w, "Failed to credit balance", http.StatusInternalServerError)
+		return
+	}
+
+	token, err := signToken(credits.SessionKey, tokenSession, session)
+	if err != nil {
+		http.Error(w, "Failed to issue session", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set(HeaderPaymentSession, token)
+
+	balance, err := credits.Balances.Debit(session.Account(), credits.Cost)
+	if errors.Is(err, ErrInsufficientBalance) {
+		rejectPayment(w, r, config, &PaymentRejection{
+			Reason:  RejectWrongAmount,
+			Payment: payment,
+			Err:     err,
+			Status:  http.StatusPaymentRequired,
+			Message: "Deposit does not cover the request",
+		})
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to debit balance", http.StatusInternalServerError)
+		return
+	}
+
+	pc.Session = session
+	pc.Balance = &balance
+	serveDebited(w, r, config, pc, next)
+}
+
+// depositAmount is what a settled payment paid. An authorization is settled for its full
+// value, overpayment included; other payments are credited the quoted amount, which is
+// all verification guaranteed.
+func depositAmount(payment *Payment, requirements *PaymentRequirements) (Amount, error) {
+	if payment.Authorization != nil {
+		return ParseAmount(payment.Authorization.Value)
+	}
+	return ParseAmount(requirements.Amount)
+}
+
+// serveDebited runs the handler for a request already debited from a balance, refunding
+// the debit if the handler does not succeed
+func serveDebited(w http.ResponseWriter, r *http.Request, config *MiddlewareConfig, pc *PaymentContext, next http.Handler) {
+	rec := &statusRecorder{ResponseWriter: w}
+	next.ServeHTTP(rec, r.WithContext(withPaymentContext(r.Context(), pc)))
+
+	if !isSuccessStatus(rec.statusCode()) {
+		config.Credits.Balances.Credit(pc.Session.Account(), config.Credits.Cost)
+	}
+}
@@ -0,0 +1,71 @@
+package x402go
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// testCreditConfig returns a credit config debiting 10 base units per request
+func testCreditConfig() *CreditConfig {
+	cost, _ := ParseAmount("10")
+	return &CreditConfig{Cost: cost, SessionKey: []byte("0123456789abcdef")}
+}
+
+func TestDepositRequiresSettlement(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("deposit mode without settlement did not panic")
+		}
+	}()
+	RequirePaymentWithConfig(&MiddlewareConfig{
+		Requirements: testRequirements(),
+		Credits:      testCreditConfig(),
+	}, http.NotFoundHandler())
+}
+
+func TestDepositCredit(t *testing.T) {
+	tests := []struct {
+		name        string
+		payment     Payment
+		settled     bool
+		wantStatus  int
+		wantBalance string
+	}{
+		{"exact authorization", Payment{Sender: "0xabc", Authorization: &TransferAuthorization{Value: "1000"}}, true, http.StatusOK, "990"},
+		{"overpaid authorization", Payment{Sender: "0xabc", Authorization: &TransferAuthorization{Value: "2500"}}, true, http.StatusOK, "2490"},
+		{"transaction", Payment{Sender: "0xabc", Amount: "2500", TxHash: "0x01"}, true, http.StatusOK, "990"},
+		{"not settled", Payment{Sender: "0xabc", Authorization: &TransferAuthorization{Value: "1000"}}, false, http.StatusPaymentRequired, "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			credits := testCreditConfig()
+			credits.init()
+			config := &MiddlewareConfig{
+				Settlement:  SettleBeforeServe,
+				Facilitator: &stubFacilitator{settle: &SettleResponse{Settled: tt.settled, TxHash: "0x01"}},
+				Credits:     credits,
+			}
+			requirements := testRequirements()
+			pc := &PaymentContext{Payment: tt.payment, Verified: true, Requirements: requirements}
+
+			rec := httptest.NewRecorder()
+			serveDeposit(rec, httptest.NewRequest("GET", "/data", nil), config, pc, requirements, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("ok"))
+			}))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+
+			session := &Session{Chain: requirements.Chain, Token: requirements.Token, Sender: tt.payment.Sender}
+			balance, err := credits.Balances.Balance(session.Account())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if balance.String() != tt.wantBalance {
+				t.Errorf("balance = %s, want %s", balance, tt.wantBalance)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package x402go
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// authorizationValidAfterSkew backdates validAfter to tolerate clock drift between payer and chain
+const authorizationValidAfterSkew = 10 * time.Minute
+
+// eip3009Types are the EIP-712 types of a transferWithAuthorization message
+var eip3009Types = apitypes.Types{
+	"EIP712Domain": {
+		{Name: "name", Type: "string"},
+		{Name: "version", Type: "string"},
+		{Name: "chainId", Type: "uint256"},
+		{Name: "verifyingContract", Type: "address"},
+	},
+	"TransferWithAuthorization": {
+		{Name: "from", Type: "address"},
+		{Name: "to", Type: "address"},
+		{Name: "value", Type: "uint256"},
+		{Name: "validAfter", Type: "uint256"},
+		{Name: "validBefore", Type: "uint256"},
+		{Name: "nonce", Type: "bytes32"},
+	},
+}
+
+// EIP712Domain identifies the token contract an EIP-3009 authorization is signed for
+type EIP712Domain struct {
+	// Name is the token's EIP-712 name (e.g. "USD Coin")
+	Name string
+
+	// Version is the token's EIP-712 version (e.g. "2")
+	Version string
+
+	// ChainID is the chain the token is deployed on
+	ChainID *big.Int
+
+	// VerifyingContract is the token contract address
+	VerifyingContract common.Address
+}
+
+// DomainForRequirements builds the EIP-712 domain of the token named in requirements.
+// The token's name and version are read from Extra["name"] and Extra["version"].
+func DomainForRequirements(requirements *PaymentRequirements) (*EIP712Domain, error) {
+	name, _ := requirements.Extra["name"].(string)
+	version, _ := requirements.Extra["version"].(string)
+	if name == "" || version == "" {
+		return nil, fmt.Errorf("requirements do not name the token's EIP-712 domain (extra.name and extra.version)")
+	}
+
+	chainID, err := ChainID(requirements.Chain)
+	if err != nil {
+		return nil, err
+	}
+
+	if !common.IsHexAddress(requirements.Token) {
+		return nil, fmt.Errorf("invalid token address %q", requirements.Token)
+	}
+
+	return &EIP712Domain{
+		Name:              name,
+		Version:           version,
+		ChainID:           chainID,
+		VerifyingContract: common.HexToAddress(requirements.Token),
+	}, nil
+}
+
+// ChainID parses a chain given as a numeric chain ID or a v1 network name
+func ChainID(chain string) (*big.Int, error) {
+	chainID, ok := new(big.Int).SetString(ChainForNetwork(chain), 10)
+	if !ok || chainID.Sign() <= 0 {
+		return nil, fmt.Errorf("unknown chain %q", chain)
+	}
+	return chainID, nil
+}
+
+// NewTransferAuthorization builds an authorization for from to pay requirements. The
+// requirements' nonce is reused as the on-chain nonce so the server can match the payment
+// to the quote; a random nonce is generated when the requirements carry none. "upto"
+// authorizations are for the maximum amount and pay the escrow named in Extra["escrow"], if any.
+func NewTransferAuthorization(from common.Address, requirements *PaymentRequirements) (*TransferAuthorization, error) {
+	to := authorizationRecipient(requirements)
+	if !common.IsHexAddress(to) {
+		return nil, fmt.Errorf("invalid recipient address %q", to)
+	}
+	if _, err := parseBaseUnits(requirements.Amount); err != nil {
+		return nil, err
+	}
+
+	nonce := requirements.Nonce
+	if nonce == "" {
+		nonce = generateNonce()
+	}
+	if b, err := hexutil.Decode(nonce); err != nil || len(b) != 32 {
+		return nil, fmt.Errorf("nonce %q is not a 32-byte hex value", nonce)
+	}
+
+	now := time.Now()
+	validBefore := requirements.Expiry
+	if validBefore == 0 {
+		validBefore = now.Add(defaultMaxTimeoutSeconds * time.Second).Unix()
+	}
+
+	return &TransferAuthorization{
+		From:        from.Hex(),
+		To:          to,
+		Value:       requirements.Amount,
+		ValidAfter:  strconv.FormatInt(now.Add(-authorizationValidAfterSkew).Unix(), 10),
+		ValidBefore: strconv.FormatInt(validBefore, 10),
+		Nonce:       nonce,
+	}, nil
+}
+
+// TypedData returns the EIP-712 typed data of the authorization under domain
+func (a *TransferAuthorization) TypedData(domain *EIP712Domain) apitypes.TypedData {
+	return apitypes.TypedData{
+		Types:       eip3009Types,
+		PrimaryType: "TransferWithAuthorization",
+		Domain: apitypes.TypedDataDomain{
+			Name:              domain.Name,
+			Version:           domain.Version,
+			ChainId:           (*math.HexOrDecimal256)(domain.ChainID),
+			VerifyingContract: domain.VerifyingContract.Hex(),
+		},
+		Message: apitypes.TypedDataMessage{
+			"from":        a.From,
+			"to":          a.To,
+			"value":       a.Value,
+			"validAfter":  a.ValidAfter,
+			"validBefore": a.ValidBefore,
+			"nonce":       a.Nonce,
+		},
+	}
+}
+
+// Hash returns the EIP-712 digest that is signed for the authorization
+func (a *TransferAuthorization) Hash(domain *EIP712Domain) ([]byte, error) {
+	hash, _, err := apitypes.TypedDataAndHash(a.TypedData(domain))
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash authorization: %w", err)
+	}
+	return hash, nil
+}
+
+// SignTransferAuthorization signs the authorization with key and returns the
+// 65-byte signature as hex, with v in the 27/28 form expected by EIP-3009 tokens
+func SignTransferAuthorization(auth *TransferAuthorization, domain *EIP712Domain, key *ecdsa.PrivateKey) (string, error) {
+	hash, err := auth.Hash(domain)
+	if err != nil {
+		return "", err
+	}
+
+	sig, err := crypto.Sign(hash, key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign authorization: %w", err)
+	}
+	sig[crypto.RecoveryIDOffset] += 27
+
+	return hexutil.Encode(sig), nil
+}
+
+// NewEIP3009PaymentHandler returns a payment handler that pays "exact" and "upto"
+// requirements by signing a transferWithAuthorization with key instead of submitting a transaction
+func NewEIP3009PaymentHandler(key *ecdsa.PrivateKey) func(*PaymentRequirements) (*Payment, error) {
+	handler := NewSignerPaymentHandler(NewPrivateKeySigner(key), nil)
+	return func(requirements *PaymentRequirements) (*Payment, error) {
+		return handler(context.Background(), requirements)
+	}
+}
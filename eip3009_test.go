@@ -0,0 +1,133 @@
+package x402go
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// testAuthorization returns an authorization and the domain it is signed under
+func testAuthorization(from common.Address) (*TransferAuthorization, *EIP712Domain) {
+	return &TransferAuthorization{
+		From:        from.Hex(),
+		To:          "0x1111111111111111111111111111111111111111",
+		Value:       "1000",
+		ValidAfter:  "0",
+		ValidBefore: "4102444800",
+		Nonce:       "0x" + common.Bytes2Hex(crypto.Keccak256([]byte("nonce"))),
+	}, &EIP712Domain{
+		Name:              "USD Coin",
+		Version:           "2",
+		ChainID:           big.NewInt(8453),
+		VerifyingContract: common.HexToAddress("0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913"),
+	}
+}
+
+func TestTransferAuthorizationHash(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	auth, domain := testAuthorization(crypto.PubkeyToAddress(key.PublicKey))
+
+	// The digest as an EIP-3009 token computes it
+	word := func(n int64) []byte { return common.LeftPadBytes(big.NewInt(n).Bytes(), 32) }
+	domainSeparator := crypto.Keccak256(
+		crypto.Keccak256([]byte("EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)")),
+		crypto.Keccak256([]byte(domain.Name)),
+		crypto.Keccak256([]byte(domain.Version)),
+		word(domain.ChainID.Int64()),
+		common.LeftPadBytes(domain.VerifyingContract.Bytes(), 32),
+	)
+	structHash := crypto.Keccak256(
+		crypto.Keccak256([]byte("TransferWithAuthorization(address from,address to,uint256 value,uint256 validAfter,uint256 validBefore,bytes32 nonce)")),
+		common.LeftPadBytes(common.HexToAddress(auth.From).Bytes(), 32),
+		common.LeftPadBytes(common.HexToAddress(auth.To).Bytes(), 32),
+		word(1000),
+		word(0),
+		word(4102444800),
+		hexutil.MustDecode(auth.Nonce),
+	)
+	want := crypto.Keccak256([]byte{0x19, 0x01}, domainSeparator, structHash)
+
+	got, err := auth.Hash(domain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("Hash() = %x, want %x", got, want)
+	}
+}
+
+func TestSignTransferAuthorization(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	auth, domain := testAuthorization(from)
+
+	signature, err := SignTransferAuthorization(auth, domain, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := hexutil.MustDecode(signature)
+	if v := sig[crypto.RecoveryIDOffset]; v != 27 && v != 28 {
+		t.Fatalf("v = %d, want 27 or 28", v)
+	}
+
+	// A Signer holding the same key produces the same signature
+	signed, err := NewPrivateKeySigner(key).SignTypedData(context.Background(), auth.TypedData(domain))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hexutil.Encode(signed) != signature {
+		t.Fatalf("SignTypedData() = %x, want %s", signed, signature)
+	}
+
+	// v in the 0/1 form is recovered too
+	raw := append([]byte(nil), sig...)
+	raw[crypto.RecoveryIDOffset] -= 27
+
+	// A high-s signature recovers the same key but tokens refuse it
+	secp256k1N := crypto.S256().Params().N
+	malleable := append([]byte(nil), sig...)
+	s := new(big.Int).Sub(secp256k1N, new(big.Int).SetBytes(sig[32:64]))
+	copy(malleable[32:64], common.LeftPadBytes(s.Bytes(), 32))
+	malleable[crypto.RecoveryIDOffset] ^= 1
+
+	otherChain := *domain
+	otherChain.ChainID = big.NewInt(1)
+	otherToken := *domain
+	otherToken.VerifyingContract = common.HexToAddress("0x2222222222222222222222222222222222222222")
+	otherValue := *auth
+	otherValue.Value = "1001"
+
+	tests := []struct {
+		name      string
+		auth      *TransferAuthorization
+		domain    *EIP712Domain
+		signature string
+		signer    bool
+		wantErr   bool
+	}{
+		{"signed", auth, domain, signature, true, false},
+		{"v as 0/1", auth, domain, hexutil.Encode(raw), true, false},
+		{"other chain", auth, &otherChain, signature, false, false},
+		{"other token", auth, &otherToken, signature, false, false},
+		{"other value", &otherValue, domain, signature, false, false},
+		{"high s", auth, domain, hexutil.Encode(malleable), false, true},
+		{"truncated", auth, domain, hexutil.Encode(sig[:64]), false, true},
+		{"not hex", auth, domain, "signature", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := recoverAuthorizationSigner(tt.auth, tt.domain, tt.signature)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && (signer == from) != tt.signer {
+				t.Errorf("recovered %s, signer %s, want match %v", signer.Hex(), from.Hex(), tt.signer)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package x402go
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/berhberhberh/x402go/internal/parse"
+)
+
+// EIP3009Verifier is a PaymentVerifier for signed transferWithAuthorization payments.
+// It recovers the signer of the EIP-712 message and checks the authorization against the
+// requirements entirely offline; it does not check the payer's balance or whether the
+// nonce has already been used on-chain.
+type EIP3009Verifier struct {
+	// Now returns the current time (optional, defaults to time.Now)
+	Now func() time.Time
+
+	// Overpayment decides whether an authorized value above the required amount is
+	// accepted (optional, defaults to AllowOverpayment)
+	Overpayment OverpaymentPolicy
+}
+
+// Verify implements PaymentVerifier
+func (v *EIP3009Verifier) Verify(payment *Payment, requirements *PaymentRequirements) (bool, error) {
+	auth := payment.Authorization
+	if auth == nil || payment.Signature == "" {
+		return false, fmt.Errorf("payment carries no EIP-3009 authorization")
+	}
+
+	// The payment must be for the advertised scheme, chain and token
+	if payment.Scheme != requirements.Scheme {
+		return false, nil
+	}
+	if ChainForNetwork(payment.Chain) != ChainForNetwork(requirements.Chain) {
+		return false, nil
+	}
+	if !strings.EqualFold(payment.Token, requirements.Token) {
+		return false, nil
+	}
+
+	// The token's EIP-712 domain binds the signature to name, version, chainId and verifyingContract
+	domain, err := DomainForRequirements(requirements)
+	if err != nil {
+		return false, err
+	}
+
+	from, err := parse.Address(auth.From)
+	if err != nil {
+		return false, err
+	}
+	to, err := parse.Address(auth.To)
+	if err != nil {
+		return false, err
+	}
+	recipient, err := parse.Address(authorizationRecipient(requirements))
+	if err != nil {
+		return false, err
+	}
+
+	// The signer must be the sender and the authorization must pay the recipient, or the
+	// escrow of an "upto" payment
+	signer, err := recoverAuthorizationSigner(auth, domain, payment.Signature)
+	if err != nil {
+		return false, err
+	}
+	if signer != from || !sameAddress(payment.Sender, from) {
+		return false, nil
+	}
+	if to != recipient {
+		return false, nil
+	}
+
+	// The authorized value must cover the required amount, the maximum for "upto" payments
+	covered, err := checkAmount(v.Overpayment, auth.Value, requirements.Amount)
+	if err != nil {
+		return false, err
+	}
+	if !covered {
+		return false, nil
+	}
+
+	// The authorization must be usable now
+	validAfter, err := parse.Uint(auth.ValidAfter, "validAfter")
+	if err != nil {
+		return false, err
+	}
+	validBefore, err := parse.Uint(auth.ValidBefore, "validBefore")
+	if err != nil {
+		return false, err
+	}
+	now := big.NewInt(v.now().Unix())
+	if now.Cmp(validAfter) <= 0 || now.Cmp(validBefore) >= 0 {
+		return false, nil
+	}
+
+	// The on-chain nonce must be the one the payment is redeemed under
+	if !strings.EqualFold(auth.Nonce, payment.Nonce) {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// now returns the verifier's current time
+func (v *EIP3009Verifier) now() time.Time {
+	if v.Now != nil {
+		return v.Now()
+	}
+	return time.Now()
+}
+
+// recoverAuthorizationSigner returns the address that signed auth under domain
+func recoverAuthorizationSigner(auth *TransferAuthorization, domain *EIP712Domain, signature string) (common.Address, error) {
+	sig, err := hexutil.Decode(signature)
+	if err != nil || len(sig) != crypto.SignatureLength {
+		return common.Address{}, fmt.Errorf("invalid signature")
+	}
+
+	// Tokens expect v as 27/28; go-ethereum recovers with 0/1
+	sig = append([]byte(nil), sig...)
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+
+	// Reject malleable signatures the token contract would refuse
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:64])
+	if !crypto.ValidateSignatureValues(sig[crypto.RecoveryIDOffset], r, s, true) {
+		return common.Address{}, fmt.Errorf("invalid signature")
+	}
+
+	hash, err := auth.Hash(domain)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	pub, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("invalid signature: %w", err)
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
+
+// sameAddress reports whether s is a hex address equal to addr
+func sameAddress(s string, addr common.Address) bool {
+	return common.IsHexAddress(s) && common.HexToAddress(s) == addr
+}
@@ -0,0 +1,123 @@
+package x402go
+
+import (
+	"crypto/ecdsa"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestEIP3009Verifier(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	other, _ := crypto.GenerateKey()
+	now := time.Now()
+	unix := func(d time.Duration) string { return strconv.FormatInt(now.Add(d).Unix(), 10) }
+
+	requirements := testRequirements()
+	requirements.Nonce = "0x0a00000000000000000000000000000000000000000000000000000000000000"
+	domain, err := DomainForRequirements(requirements)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// pay signs the authorization for requirements, as changed by edit, with signer
+	pay := func(signer *ecdsa.PrivateKey, edit func(*TransferAuthorization, *Payment)) *Payment {
+		auth, err := NewTransferAuthorization(crypto.PubkeyToAddress(key.PublicKey), requirements)
+		if err != nil {
+			t.Fatal(err)
+		}
+		auth.ValidAfter = unix(-time.Minute)
+		auth.ValidBefore = unix(time.Minute)
+		payment := &Payment{
+			Scheme:    requirements.Scheme,
+			Chain:     requirements.Chain,
+			Token:     requirements.Token,
+			Sender:    auth.From,
+			Recipient: auth.To,
+			Nonce:     auth.Nonce,
+		}
+		if edit != nil {
+			edit(auth, payment)
+		}
+		signature, err := SignTransferAuthorization(auth, domain, signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		payment.Amount = auth.Value
+		payment.Signature = signature
+		payment.Authorization = auth
+		return payment
+	}
+
+	tests := []struct {
+		name    string
+		signer  *ecdsa.PrivateKey
+		edit    func(*TransferAuthorization, *Payment)
+		want    bool
+		wantErr bool
+	}{
+		{"valid", key, nil, true, false},
+		{"wrong signer", other, nil, false, false},
+		{"sender is not the signer", key, func(a *TransferAuthorization, p *Payment) {
+			p.Sender = crypto.PubkeyToAddress(other.PublicKey).Hex()
+		}, false, false},
+		{"to is not the recipient", key, func(a *TransferAuthorization, p *Payment) {
+			a.To = "0x2222222222222222222222222222222222222222"
+		}, false, false},
+		{"value below amount", key, func(a *TransferAuthorization, p *Payment) { a.Value = "999" }, false, false},
+		{"value above amount", key, func(a *TransferAuthorization, p *Payment) { a.Value = "1001" }, true, false},
+		{"hex value", key, func(a *TransferAuthorization, p *Payment) { a.Value = "0x3e8" }, true, false},
+		{"validAfter in future", key, func(a *TransferAuthorization, p *Payment) { a.ValidAfter = unix(time.Minute) }, false, false},
+		{"validAfter now", key, func(a *TransferAuthorization, p *Payment) { a.ValidAfter = unix(0) }, false, false},
+		{"validBefore in past", key, func(a *TransferAuthorization, p *Payment) { a.ValidBefore = unix(-time.Second) }, false, false},
+		{"validBefore now", key, func(a *TransferAuthorization, p *Payment) { a.ValidBefore = unix(0) }, false, false},
+		{"hex validity window", key, func(a *TransferAuthorization, p *Payment) {
+			a.ValidAfter = "0x" + strconv.FormatInt(now.Add(-time.Minute).Unix(), 16)
+			a.ValidBefore = "0X" + strconv.FormatInt(now.Add(time.Minute).Unix(), 16)
+		}, true, false},
+		{"nonce mismatch", key, func(a *TransferAuthorization, p *Payment) {
+			p.Nonce = "0x0b00000000000000000000000000000000000000000000000000000000000000"
+		}, false, false},
+		{"other scheme", key, func(a *TransferAuthorization, p *Payment) { p.Scheme = SchemeUpTo }, false, false},
+		{"other token", key, func(a *TransferAuthorization, p *Payment) {
+			p.Token = "0x2222222222222222222222222222222222222222"
+		}, false, false},
+	}
+
+	verifier := &EIP3009Verifier{Now: func() time.Time { return now }}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			valid, err := verifier.Verify(pay(tt.signer, tt.edit), requirements)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if valid != tt.want {
+				t.Errorf("Verify() = %v, want %v", valid, tt.want)
+			}
+		})
+	}
+
+	// Fields that cannot be signed are errors
+	malformed := []func(*TransferAuthorization){
+		func(a *TransferAuthorization) { a.Value = "-1000" },
+		func(a *TransferAuthorization) { a.Value = "1e3" },
+		func(a *TransferAuthorization) { a.ValidBefore = "soon" },
+		func(a *TransferAuthorization) { a.To = "0xYourAddress" },
+	}
+	for i, edit := range malformed {
+		payment := pay(key, nil)
+		edit(payment.Authorization)
+		if valid, err := verifier.Verify(payment, requirements); err == nil || valid {
+			t.Errorf("malformed authorization %d: Verify() = %v, %v, want an error", i, valid, err)
+		}
+	}
+
+	// An exact-amount policy refuses overpayment
+	exact := &EIP3009Verifier{Now: verifier.Now, Overpayment: RejectOverpayment}
+	overpaid := pay(key, func(a *TransferAuthorization, p *Payment) { a.Value = "1001" })
+	if valid, err := exact.Verify(overpaid, requirements); err != nil || valid {
+		t.Errorf("overpayment with RejectOverpayment: Verify() = %v, %v, want false", valid, err)
+	}
+}
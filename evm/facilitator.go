@@ -0,0 +1,456 @@
+// Package evm implements an x402go.Facilitator that settles EIP-3009
+// transferWithAuthorization payments on EVM chains from a relayer account.
+package evm
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/berhberhberh/x402go"
+	"github.com/berhberhberh/x402go/internal/parse"
+)
+
+// eip3009ABI is the part of the EIP-3009 token interface used by the facilitator
+const eip3009ABI = `[{
+	"type": "function",
+	"name": "transfer",
+	"stateMutability": "nonpayable",
+	"inputs": [
+		{"name": "to", "type": "address"},
+		{"name": "value", "type": "uint256"}
+	],
+	"outputs": [{"name": "", "type": "bool"}]
+}, {
+	"type": "function",
+	"name": "transferWithAuthorization",
+	"stateMutability": "nonpayable",
+	"inputs": [
+		{"name": "from", "type": "address"},
+		{"name": "to", "type": "address"},
+		{"name": "value", "type": "uint256"},
+		{"name": "validAfter", "type": "uint256"},
+		{"name": "validBefore", "type": "uint256"},
+		{"name": "nonce", "type": "bytes32"},
+		{"name": "v", "type": "uint8"},
+		{"name": "r", "type": "bytes32"},
+		{"name": "s", "type": "bytes32"}
+	],
+	"outputs": []
+}]`
+
+// tokenABI is the parsed eip3009ABI
+var tokenABI = mustParseABI(eip3009ABI)
+
+// defaultReceiptTimeout bounds how long Settle waits for its transactions to be mined
+const defaultReceiptTimeout = 2 * time.Minute
+
+// Backend is the chain access needed by the facilitator: contract calls to simulate
+// authorizations, transaction submission from the relayer and receipts to wait for mining.
+type Backend interface {
+	bind.ContractBackend
+	bind.DeployBackend
+}
+
+// Facilitator verifies EIP-3009 authorizations and settles them by submitting
+// transferWithAuthorization from a relayer account, which pays the gas.
+//
+// "upto" payments can be charged less than their authorized maximum if the authorization
+// pays the relayer, named as the escrow in the requirements' Extra["escrow"]. The
+// facilitator then forwards the charged amount to the recipient and refunds the rest.
+// The requirements in a settle request are not signed by anyone, so escrowed funds are
+// only forwarded to the recipients listed in EscrowRecipients.
+type Facilitator struct {
+	backend Backend
+	key     *ecdsa.PrivateKey
+	chainID *big.Int
+
+	// verifier checks authorizations offline before they are simulated
+	verifier *x402go.EIP3009Verifier
+
+	// sendMu serializes submissions so relayer nonces are not reused
+	sendMu sync.Mutex
+
+	// ReceiptTimeout bounds how long Settle waits for its transactions to be mined, the
+	// forward and refund of escrowed payments included (default: 2 minutes). Facilitator
+	// clients must wait longer than this for settle responses.
+	ReceiptTimeout time.Duration
+
+	// EscrowRecipients are the accounts escrowed payments may be forwarded to. Escrowed
+	// payments to any other recipient are refused before the authorization is submitted.
+	EscrowRecipients []common.Address
+}
+
+// New creates a facilitator for the chain with chainID, submitting transactions through
+// backend from the account of relayerKey
+func New(backend Backend, relayerKey *ecdsa.PrivateKey, chainID *big.Int) *Facilitator {
+	return &Facilitator{
+		backend:        backend,
+		key:            relayerKey,
+		chainID:        chainID,
+		verifier:       &x402go.EIP3009Verifier{},
+		ReceiptTimeout: defaultReceiptTimeout,
+	}
+}
+
+// Relayer returns the address that submits settlement transactions
+func (f *Facilitator) Relayer() common.Address {
+	return crypto.PubkeyToAddress(f.key.PublicKey)
+}
+
+// Verify implements x402go.Facilitator
+func (f *Facilitator) Verify(req *x402go.VerifyRequest) (*x402go.VerifyResponse, error) {
+	return f.VerifyContext(context.Background(), req)
+}
+
+// VerifyContext implements x402go.ContextFacilitator. The authorization is checked offline and
+// then simulated against the latest block, so spent nonces and insufficient balances are caught.
+func (f *Facilitator) VerifyContext(ctx context.Context, req *x402go.VerifyRequest) (*x402go.VerifyResponse, error) {
+	if req.Payment == nil || req.Requirements == nil {
+		return invalid(req, "verify request carries no payment and requirements"), nil
+	}
+
+	call, reason, err := f.prepare(req.Payment, req.Requirements)
+	if err != nil {
+		return nil, err
+	}
+	if reason != "" {
+		return invalid(req, reason), nil
+	}
+
+	if err := call.simulate(ctx, f.Relayer()); err != nil {
+		return invalid(req, "simulation failed: "+err.Error()), nil
+	}
+
+	auth := req.Payment.Authorization
+	return &x402go.VerifyResponse{
+		Valid:     true,
+		Chain:     req.Requirements.Chain,
+		Token:     req.Requirements.Token,
+		Amount:    auth.Value,
+		Sender:    auth.From,
+		Recipient: auth.To,
+	}, nil
+}
+
+// Settle implements x402go.Facilitator
+func (f *Facilitator) Settle(req *x402go.SettleRequest) (*x402go.SettleResponse, error) {
+	return f.SettleContext(context.Background(), req)
+}
+
+// SettleContext implements x402go.ContextFacilitator. It submits transferWithAuthorization
+// and waits for the transaction to be mined. Escrowed payments are then forwarded to the
+// recipient, and the response names the forwarding transaction; if forwarding fails the
+// payment is still reported settled, with the failure in Error. All of it takes at most
+// ReceiptTimeout.
+func (f *Facilitator) SettleContext(ctx context.Context, req *x402go.SettleRequest) (*x402go.SettleResponse, error) {
+	if req.Requirements == nil {
+		return &x402go.SettleResponse{Error: "settle request carries no requirements"}, nil
+	}
+
+	call, reason, err := f.prepare(&req.Payment, req.Requirements)
+	if err != nil {
+		return nil, err
+	}
+	if reason != "" {
+		return &x402go.SettleResponse{Error: reason}, nil
+	}
+
+	charge, reason := f.charge(req, call)
+	if reason != "" {
+		return &x402go.SettleResponse{Error: reason}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, f.ReceiptTimeout)
+	defer cancel()
+
+	// Simulate first so a doomed authorization does not cost gas
+	if err := call.simulate(ctx, f.Relayer()); err != nil {
+		return &x402go.SettleResponse{Error: "simulation failed: " + err.Error()}, nil
+	}
+
+	tx, err := f.send(ctx, call.contract, "transferWithAuthorization", call.args...)
+	if err != nil {
+		return nil, err
+	}
+	if tx.reverted {
+		return &x402go.SettleResponse{
+			TxHash: tx.hash,
+			Error:  "transaction reverted",
+		}, nil
+	}
+	if charge == nil {
+		return &x402go.SettleResponse{
+			Settled:   true,
+			TxHash:    tx.hash,
+			Timestamp: time.Now().Unix(),
+		}, nil
+	}
+
+	return f.forward(ctx, call, charge, tx.hash), nil
+}
+
+// escrowCharge is the split of an escrowed payment between recipient and payer
+type escrowCharge struct {
+	recipient common.Address
+	payer     common.Address
+	amount    *big.Int
+	refund    *big.Int
+}
+
+// charge works out how an authorization is settled. A nil charge settles the authorization
+// as is; otherwise the relayer holds the funds and splits them. A non-empty reason means the
+// requested amount cannot be charged.
+func (f *Facilitator) charge(req *x402go.SettleRequest, call *authorizationCall) (*escrowCharge, string) {
+	value := call.value
+	amount := value
+	if req.Amount != "" {
+		charged, err := parse.Uint(req.Amount, "amount")
+		if err != nil {
+			return nil, err.Error()
+		}
+		if charged.Cmp(value) > 0 {
+			return nil, fmt.Sprintf("amount %s exceeds the authorized value %s", charged, value)
+		}
+		if charged.Cmp(value) < 0 && req.Requirements.Scheme != x402go.SchemeUpTo {
+			return nil, fmt.Sprintf("%q payments are settled in full", req.Requirements.Scheme)
+		}
+		amount = charged
+	}
+
+	// Authorizations paying the recipient directly can only be settled in full
+	recipient := common.HexToAddress(req.Requirements.Recipient)
+	if call.to != f.Relayer() || recipient == f.Relayer() {
+		if amount.Cmp(value) != 0 {
+			return nil, "partial settlement requires the authorization to pay the facilitator's escrow"
+		}
+		return nil, ""
+	}
+
+	if !f.escrowRecipient(recipient) {
+		return nil, fmt.Sprintf("recipient %s is not an escrow recipient of this facilitator", recipient.Hex())
+	}
+
+	return &escrowCharge{
+		recipient: recipient,
+		payer:     call.from,
+		amount:    amount,
+		refund:    new(big.Int).Sub(value, amount),
+	}, ""
+}
+
+// escrowRecipient reports whether escrowed payments may be forwarded to recipient
+func (f *Facilitator) escrowRecipient(recipient common.Address) bool {
+	for _, allowed := range f.EscrowRecipients {
+		if allowed == recipient {
+			return true
+		}
+	}
+	return false
+}
+
+// forward pays the charged amount of an escrowed payment to the recipient and refunds the
+// rest to the payer. The payer is charged once the authorization is mined, so the payment is
+// settled even if forwarding or refunding fails. The failure is then reported in Error, and
+// a failed forward leaves TxHash naming the authorization and the funds with the relayer.
+func (f *Facilitator) forward(ctx context.Context, call *authorizationCall, charge *escrowCharge, authorized string) *x402go.SettleResponse {
+	resp := &x402go.SettleResponse{Settled: true, TxHash: authorized}
+
+	if charge.amount.Sign() > 0 {
+		tx, err := f.send(ctx, call.contract, "transfer", charge.recipient, charge.amount)
+		switch {
+		case err != nil:
+			resp.Error = "forward failed: " + err.Error()
+		case tx.reverted:
+			resp.Error = "forwarding transaction " + tx.hash + " reverted"
+		default:
+			resp.TxHash = tx.hash
+		}
+	}
+
+	if resp.Error == "" && charge.refund.Sign() > 0 {
+		tx, err := f.send(ctx, call.contract, "transfer", charge.payer, charge.refund)
+		if err != nil {
+			resp.Error = "refund failed: " + err.Error()
+		} else if tx.reverted {
+			resp.Error = "refund transaction " + tx.hash + " reverted"
+		}
+	}
+
+	resp.Timestamp = time.Now().Unix()
+	return resp
+}
+
+// prepare checks a payment against its requirements and builds the transferWithAuthorization
+// call. A non-empty reason means the payment is invalid.
+func (f *Facilitator) prepare(payment *x402go.Payment, requirements *x402go.PaymentRequirements) (*authorizationCall, string, error) {
+	chainID, err := x402go.ChainID(requirements.Chain)
+	if err != nil || chainID.Cmp(f.chainID) != 0 {
+		return nil, fmt.Sprintf("unsupported chain %q", requirements.Chain), nil
+	}
+
+	valid, err := f.verifier.Verify(payment, requirements)
+	if err != nil {
+		return nil, err.Error(), nil
+	}
+	if !valid {
+		return nil, "invalid authorization", nil
+	}
+
+	call, err := newAuthorizationCall(f.backend, requirements.Token, payment)
+	if err != nil {
+		return nil, err.Error(), nil
+	}
+	return call, "", nil
+}
+
+// sentTransaction is a mined transaction sent by the relayer
+type sentTransaction struct {
+	hash     string
+	reverted bool
+}
+
+// send submits a contract call from the relayer and waits for it to be mined
+func (f *Facilitator) send(ctx context.Context, contract *bind.BoundContract, method string, args ...interface{}) (*sentTransaction, error) {
+	tx, err := f.submit(ctx, contract, method, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to submit transaction: %w", err)
+	}
+
+	receipt, err := bind.WaitMined(ctx, f.backend, tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed waiting for transaction %s: %w", tx.Hash().Hex(), err)
+	}
+
+	return &sentTransaction{
+		hash:     tx.Hash().Hex(),
+		reverted: receipt.Status != types.ReceiptStatusSuccessful,
+	}, nil
+}
+
+// submit sends a contract call transaction from the relayer
+func (f *Facilitator) submit(ctx context.Context, contract *bind.BoundContract, method string, args ...interface{}) (*types.Transaction, error) {
+	opts, err := bind.NewKeyedTransactorWithChainID(f.key, f.chainID)
+	if err != nil {
+		return nil, err
+	}
+	opts.Context = ctx
+
+	f.sendMu.Lock()
+	defer f.sendMu.Unlock()
+
+	return contract.Transact(opts, method, args...)
+}
+
+// authorizationCall is a transferWithAuthorization call on a token contract
+type authorizationCall struct {
+	contract *bind.BoundContract
+	args     []interface{}
+
+	from  common.Address
+	to    common.Address
+	value *big.Int
+}
+
+// newAuthorizationCall builds the transferWithAuthorization call for a signed payment
+func newAuthorizationCall(backend Backend, token string, payment *x402go.Payment) (*authorizationCall, error) {
+	auth := payment.Authorization
+	if auth == nil {
+		return nil, fmt.Errorf("payment carries no authorization")
+	}
+
+	if !common.IsHexAddress(token) {
+		return nil, fmt.Errorf("invalid token address %q", token)
+	}
+	from, err := parse.Address(auth.From)
+	if err != nil {
+		return nil, err
+	}
+	to, err := parse.Address(auth.To)
+	if err != nil {
+		return nil, err
+	}
+	value, err := parse.Uint(auth.Value, "value")
+	if err != nil {
+		return nil, err
+	}
+	validAfter, err := parse.Uint(auth.ValidAfter, "validAfter")
+	if err != nil {
+		return nil, err
+	}
+	validBefore, err := parse.Uint(auth.ValidBefore, "validBefore")
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := parseBytes32(auth.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := hexutil.Decode(payment.Signature)
+	if err != nil || len(sig) != crypto.SignatureLength {
+		return nil, fmt.Errorf("invalid signature")
+	}
+	var r, s [32]byte
+	copy(r[:], sig[:32])
+	copy(s[:], sig[32:64])
+	v := sig[crypto.RecoveryIDOffset]
+	if v < 27 {
+		v += 27
+	}
+
+	address := common.HexToAddress(token)
+	return &authorizationCall{
+		contract: bind.NewBoundContract(address, tokenABI, backend, backend, backend),
+		args:     []interface{}{from, to, value, validAfter, validBefore, nonce, v, r, s},
+		from:     from,
+		to:       to,
+		value:    value,
+	}, nil
+}
+
+// simulate executes the call against the latest block without submitting it
+func (c *authorizationCall) simulate(ctx context.Context, from common.Address) error {
+	return c.contract.Call(&bind.CallOpts{From: from, Context: ctx}, nil, "transferWithAuthorization", c.args...)
+}
+
+// invalid builds the response for a payment that failed verification
+func invalid(req *x402go.VerifyRequest, reason string) *x402go.VerifyResponse {
+	return &x402go.VerifyResponse{
+		Valid:  false,
+		TxHash: req.TxHash,
+		Chain:  req.Chain,
+		Error:  reason,
+	}
+}
+
+// parseBytes32 parses a 0x-prefixed 32-byte hex value
+func parseBytes32(s string) ([32]byte, error) {
+	var out [32]byte
+	b, err := hexutil.Decode(s)
+	if err != nil || len(b) != 32 {
+		return out, fmt.Errorf("invalid bytes32 %q", s)
+	}
+	copy(out[:], b)
+	return out, nil
+}
+
+// mustParseABI parses a JSON ABI definition, panicking on error
+func mustParseABI(definition string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(definition))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
@@ -0,0 +1,262 @@
+package evm
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/berhberhberh/x402go"
+	"github.com/berhberhberh/x402go/internal/testtoken"
+)
+
+// simulatedChainID is the chain ID of go-ethereum's simulated backend
+var simulatedChainID = big.NewInt(1337)
+
+// minedBackend mines every transaction as soon as it is sent. The failAt'th send, counting
+// from 1, fails instead (0 never fails).
+type minedBackend struct {
+	*backends.SimulatedBackend
+
+	sent   int
+	failAt int
+}
+
+// SendTransaction implements bind.ContractTransactor
+func (b *minedBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	b.sent++
+	if b.sent == b.failAt {
+		return errors.New("send failed")
+	}
+	if err := b.SimulatedBackend.SendTransaction(ctx, tx); err != nil {
+		return err
+	}
+	b.Commit()
+	return nil
+}
+
+// testChain is a simulated chain with a deployed test token
+type testChain struct {
+	backend *minedBackend
+	token   *testtoken.Token
+	relayer *ecdsa.PrivateKey
+	payer   *ecdsa.PrivateKey
+}
+
+// newTestChain deploys the test token and mints balance to a fresh payer
+func newTestChain(t *testing.T, balance int64) *testChain {
+	t.Helper()
+
+	relayer, _ := crypto.GenerateKey()
+	payer, _ := crypto.GenerateKey()
+	ether := new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+	sim := backends.NewSimulatedBackend(core.GenesisAlloc{
+		crypto.PubkeyToAddress(relayer.PublicKey): {Balance: ether},
+		crypto.PubkeyToAddress(payer.PublicKey):   {Balance: ether},
+	}, 30_000_000)
+	t.Cleanup(func() { sim.Close() })
+
+	// Move the chain's clock close to now so authorizations are within their validity
+	// window. It trails the wall clock as every block adds 10 seconds and the chain refuses
+	// blocks from the future.
+	if err := sim.AdjustTime(time.Duration(time.Now().Add(-5*time.Minute).Unix()) * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	sim.Commit()
+
+	backend := &minedBackend{SimulatedBackend: sim}
+	opts, err := bind.NewKeyedTransactorWithChainID(relayer, simulatedChainID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := testtoken.Deploy(opts, backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := token.Mint(opts, crypto.PubkeyToAddress(payer.PublicKey), big.NewInt(balance)); err != nil {
+		t.Fatal(err)
+	}
+
+	return &testChain{backend: backend, token: token, relayer: relayer, payer: payer}
+}
+
+// requirements returns requirements paying amount of the test token to recipient
+func (c *testChain) requirements(scheme, amount string, recipient common.Address) *x402go.PaymentRequirements {
+	return &x402go.PaymentRequirements{
+		Scheme:    scheme,
+		Amount:    amount,
+		Token:     c.token.Address.Hex(),
+		Chain:     simulatedChainID.String(),
+		Recipient: recipient.Hex(),
+		Extra:     map[string]interface{}{"name": testtoken.Name, "version": testtoken.Version},
+	}
+}
+
+// pay signs a payment from the payer for requirements
+func (c *testChain) pay(t *testing.T, requirements *x402go.PaymentRequirements) *x402go.Payment {
+	t.Helper()
+	payment, err := x402go.NewEIP3009PaymentHandler(c.payer)(requirements)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return payment
+}
+
+// balance returns the token balance of account
+func (c *testChain) balance(t *testing.T, account common.Address) int64 {
+	t.Helper()
+	balance, err := c.token.BalanceOf(&bind.CallOpts{}, account)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return balance.Int64()
+}
+
+func TestFacilitatorSettle(t *testing.T) {
+	chain := newTestChain(t, 1000)
+	f := New(chain.backend, chain.relayer, simulatedChainID)
+	recipient := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	payer := crypto.PubkeyToAddress(chain.payer.PublicKey)
+
+	requirements := chain.requirements(x402go.SchemeExact, "400", recipient)
+	payment := chain.pay(t, requirements)
+
+	verified, err := f.Verify(&x402go.VerifyRequest{Payment: payment, Requirements: requirements})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !verified.Valid {
+		t.Fatalf("payment not verified: %s", verified.Error)
+	}
+
+	resp, err := f.Settle(&x402go.SettleRequest{Payment: *payment, Requirements: requirements})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Settled || resp.Error != "" || resp.TxHash == "" {
+		t.Fatalf("got %+v, want settled", resp)
+	}
+	if got := chain.balance(t, recipient); got != 400 {
+		t.Errorf("recipient balance = %d, want 400", got)
+	}
+	if got := chain.balance(t, payer); got != 600 {
+		t.Errorf("payer balance = %d, want 600", got)
+	}
+
+	// The token refuses to spend the same authorization twice
+	resp, err = f.Settle(&x402go.SettleRequest{Payment: *payment, Requirements: requirements})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Settled || !strings.Contains(resp.Error, "simulation failed") {
+		t.Errorf("replayed authorization: got %+v, want a failed simulation", resp)
+	}
+}
+
+func TestFacilitatorVerifyInsufficientBalance(t *testing.T) {
+	chain := newTestChain(t, 100)
+	f := New(chain.backend, chain.relayer, simulatedChainID)
+
+	requirements := chain.requirements(x402go.SchemeExact, "400", common.HexToAddress("0x1111111111111111111111111111111111111111"))
+	payment := chain.pay(t, requirements)
+
+	resp, err := f.Verify(&x402go.VerifyRequest{Payment: payment, Requirements: requirements})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Valid || !strings.Contains(resp.Error, "simulation failed") {
+		t.Errorf("got %+v, want a failed simulation", resp)
+	}
+}
+
+func TestFacilitatorSettleEscrow(t *testing.T) {
+	recipient := common.HexToAddress("0x1111111111111111111111111111111111111111")
+
+	tests := []struct {
+		name      string
+		failAt    int
+		settled   bool
+		err       string
+		recipient int64
+		relayer   int64
+		payer     int64
+	}{
+		{"forwarded and refunded", 0, true, "", 300, 0, 700},
+		{"forward fails", 2, true, "forward failed", 0, 1000, 0},
+		{"refund fails", 3, true, "refund failed", 300, 700, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := newTestChain(t, 1000)
+			f := New(chain.backend, chain.relayer, simulatedChainID)
+			f.EscrowRecipients = []common.Address{recipient}
+
+			requirements := chain.requirements(x402go.SchemeUpTo, "1000", recipient)
+			requirements.Extra["escrow"] = f.Relayer().Hex()
+			payment := chain.pay(t, requirements)
+
+			if tt.failAt > 0 {
+				chain.backend.failAt = chain.backend.sent + tt.failAt
+			}
+			resp, err := f.Settle(&x402go.SettleRequest{Payment: *payment, Amount: "300", Requirements: requirements})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Settled != tt.settled || !strings.Contains(resp.Error, tt.err) || resp.TxHash == "" {
+				t.Errorf("got %+v, want settled %v with error %q", resp, tt.settled, tt.err)
+			}
+			if (tt.err == "") != (resp.Error == "") {
+				t.Errorf("error = %q, want %q", resp.Error, tt.err)
+			}
+
+			if got := chain.balance(t, recipient); got != tt.recipient {
+				t.Errorf("recipient balance = %d, want %d", got, tt.recipient)
+			}
+			if got := chain.balance(t, f.Relayer()); got != tt.relayer {
+				t.Errorf("relayer balance = %d, want %d", got, tt.relayer)
+			}
+			if got := chain.balance(t, crypto.PubkeyToAddress(chain.payer.PublicKey)); got != tt.payer {
+				t.Errorf("payer balance = %d, want %d", got, tt.payer)
+			}
+		})
+	}
+}
+
+func TestFacilitatorSettleEscrowRecipient(t *testing.T) {
+	chain := newTestChain(t, 1000)
+	f := New(chain.backend, chain.relayer, simulatedChainID)
+	f.EscrowRecipients = []common.Address{common.HexToAddress("0x1111111111111111111111111111111111111111")}
+	other := common.HexToAddress("0x2222222222222222222222222222222222222222")
+
+	requirements := chain.requirements(x402go.SchemeUpTo, "1000", other)
+	requirements.Extra["escrow"] = f.Relayer().Hex()
+	payment := chain.pay(t, requirements)
+
+	// A settle request naming a recipient the facilitator does not forward to is refused
+	// before anything is submitted
+	sent := chain.backend.sent
+	resp, err := f.Settle(&x402go.SettleRequest{Payment: *payment, Amount: "300", Requirements: requirements})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Settled || !strings.Contains(resp.Error, "not an escrow recipient") {
+		t.Errorf("got %+v, want the recipient refused", resp)
+	}
+	if chain.backend.sent != sent {
+		t.Errorf("%d transactions sent, want none", chain.backend.sent-sent)
+	}
+	if got := chain.balance(t, crypto.PubkeyToAddress(chain.payer.PublicKey)); got != 1000 {
+		t.Errorf("payer balance = %d, want 1000", got)
+	}
+}
@@ -1,52 +1,53 @@
 package main
 
 import (
+	"context"
+	"crypto/ecdsa"
 	"fmt"
 	"io"
 	"log"
-	"time"
+	"os"
+
+	"github.com/ethereum/go-ethereum/crypto"
 
 	"github.com/berhberhberh/x402go"
 )
 
 func main() {
-	// Create a payment handler that simulates making a blockchain transaction
-	paymentHandler := func(requirements *x402go.PaymentRequirements) (*x402go.Payment, error) {
+	// Load the payer's key from KEYSTORE or PRIVATE_KEY, or generate a throwaway key for the demo
+	signer, err := loadSigner()
+	if err != nil {
+		log.Fatalf("Failed to load key: %v", err)
+	}
+
+	// Sign EIP-3009 transferWithAuthorization messages instead of sending transactions,
+	// so the payer needs no gas and does not wait for confirmations
+	pay := x402go.NewSignerPaymentHandler(signer, nil)
+
+	paymentHandler := func(ctx context.Context, requirements *x402go.PaymentRequirements) (*x402go.Payment, error) {
+		amount, err := x402go.ParseAmount(requirements.Amount)
+		if err != nil {
+			return nil, err
+		}
+
 		fmt.Printf("Payment Required:\n")
-		fmt.Printf("  Amount: %s\n", requirements.Amount)
+		fmt.Printf("  Amount: %s\n", x402go.DefaultTokens.FormatAmount(requirements.Chain, requirements.Token, amount))
 		fmt.Printf("  Token: %s\n", requirements.Token)
 		fmt.Printf("  Chain: %s\n", requirements.Chain)
 		fmt.Printf("  Recipient: %s\n", requirements.Recipient)
-		fmt.Printf("\nProcessing payment...\n")
-
-		// In a real implementation, you would:
-		// 1. Connect to a wallet
-		// 2. Sign a transaction
-		// 3. Submit to blockchain
-		// 4. Wait for confirmation
-		// 5. Return the transaction hash
+		fmt.Printf("\nSigning payment authorization...\n")
 
-		// For demo purposes, we'll simulate a transaction
-		simulatedTxHash := "0x1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef"
-
-		payment := &x402go.Payment{
-			Scheme:    requirements.Scheme,
-			TxHash:    simulatedTxHash,
-			Chain:     requirements.Chain,
-			Token:     requirements.Token,
-			Amount:    requirements.Amount,
-			Sender:    "0x742d35Cc6634C0532925a3b844Bc9e7595f0bEb",
-			Recipient: requirements.Recipient,
-			Nonce:     requirements.Nonce,
-			Timestamp: time.Now().Unix(),
+		payment, err := pay(ctx, requirements)
+		if err != nil {
+			return nil, err
 		}
 
-		fmt.Printf("Payment sent! TxHash: %s\n\n", simulatedTxHash)
+		fmt.Printf("Authorization signed by %s\n\n", payment.Sender)
 		return payment, nil
 	}
 
 	// Create client with payment handler
-	client := x402go.NewClientWithHandler(paymentHandler)
+	client := x402go.NewClientWithContextHandler(paymentHandler)
 
 	// Make a request to a protected endpoint
 	fmt.Println("Requesting protected resource...")
@@ -64,3 +65,23 @@ func main() {
 	fmt.Printf("Response Status: %s\n", resp.Status)
 	fmt.Printf("Response Body: %s\n", string(body))
 }
+
+// loadSigner decrypts the keystore file at KEYSTORE with KEYSTORE_PASSPHRASE, reads a hex
+// private key from PRIVATE_KEY, or generates a new key
+func loadSigner() (x402go.Signer, error) {
+	if path := os.Getenv("KEYSTORE"); path != "" {
+		return x402go.NewKeystoreSigner(path, os.Getenv("KEYSTORE_PASSPHRASE"))
+	}
+
+	var key *ecdsa.PrivateKey
+	var err error
+	if hexKey := os.Getenv("PRIVATE_KEY"); hexKey != "" {
+		key, err = crypto.HexToECDSA(hexKey)
+	} else {
+		key, err = crypto.GenerateKey()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return x402go.NewPrivateKeySigner(key), nil
+}
@@ -1,12 +1,18 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"time"
 
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+
 	"github.com/berhberhberh/x402go"
+	"github.com/berhberhberh/x402go/evm"
 )
 
 // MockFacilitator is a simple facilitator implementation for demonstration
@@ -29,8 +35,8 @@ func (f *MockFacilitator) Verify(req *x402go.VerifyRequest) (*x402go.VerifyRespo
 		Chain:     req.Chain,
 		Token:     "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48",
 		Amount:    "1000000",
-		Sender:    "0x742d35Cc6634C0532925a3b844Bc9e7595f0bEb",
-		Recipient: "0x742d35Cc6634C0532925a3b844Bc9e7595f0bEb",
+		Sender:    "0x742D35CC6634c0532925A3b844BC9E7595F0BEb0",
+		Recipient: "0x742D35CC6634c0532925A3b844BC9E7595F0BEb0",
 	}, nil
 }
 
@@ -51,8 +57,12 @@ func (f *MockFacilitator) Settle(req *x402go.SettleRequest) (*x402go.SettleRespo
 }
 
 func main() {
-	// Create facilitator
-	facilitator := &MockFacilitator{}
+	// Create facilitator: settle on-chain when an RPC endpoint and relayer key are
+	// configured, otherwise fall back to the mock
+	facilitator, err := newFacilitator()
+	if err != nil {
+		log.Fatalf("Failed to create facilitator: %v", err)
+	}
 
 	// Create facilitator server
 	server := x402go.NewFacilitatorServer(facilitator)
@@ -65,3 +75,30 @@ func main() {
 
 	log.Fatal(http.ListenAndServe(":8081", server))
 }
+
+// newFacilitator creates an EVM facilitator from RPC_URL and RELAYER_KEY, or a mock if they are unset
+func newFacilitator() (x402go.Facilitator, error) {
+	rpcURL := os.Getenv("RPC_URL")
+	relayerKey := os.Getenv("RELAYER_KEY")
+	if rpcURL == "" || relayerKey == "" {
+		return &MockFacilitator{}, nil
+	}
+
+	key, err := crypto.HexToECDSA(relayerKey)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := ethclient.Dial(rpcURL)
+	if err != nil {
+		return nil, err
+	}
+
+	chainID, err := client.ChainID(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("Settling on chain %s\n", chainID)
+	return evm.New(client, key, chainID), nil
+}
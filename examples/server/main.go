@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 
 	"github.com/berhberhberh/x402go"
 )
@@ -12,10 +13,15 @@ func main() {
 	// Define payment requirements
 	requirements := &x402go.PaymentRequirements{
 		Scheme:    x402go.SchemeExact,
-		Amount:    "1000000", // 1 USDC (6 decimals)
-		Token:     "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48",
-		Chain:     "8453", // Base
-		Recipient: "0x742d35Cc6634C0532925a3b844Bc9e7595f0bEb",
+		Amount:    "1000000",                                    // 1 USDC (6 decimals)
+		Token:     "0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913", // USDC on Base
+		Chain:     "8453",                                       // Base
+		Recipient: "0x742D35CC6634c0532925A3b844BC9E7595F0BEb0",
+		// EIP-712 domain of USDC, used by clients to sign transferWithAuthorization
+		Extra: map[string]interface{}{
+			"name":    "USD Coin",
+			"version": "2",
+		},
 	}
 
 	// Create a protected endpoint
@@ -32,17 +38,42 @@ func main() {
 			payment.Payment.TxHash, payment.Payment.Amount)
 	})
 
-	// Wrap with payment middleware
-	http.Handle("/premium", x402go.RequirePayment(requirements, protectedHandler))
+	app := http.NewServeMux()
+	app.Handle("/premium", protectedHandler)
 
 	// Free endpoint for comparison
-	http.HandleFunc("/free", func(w http.ResponseWriter, r *http.Request) {
+	app.HandleFunc("/free", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		fmt.Fprint(w, `{"message": "Free content!"}`)
 	})
 
+	// Charge for priced routes, verifying signed EIP-3009 authorizations offline
+	paywall := x402go.NewPaywallMux(&x402go.MiddlewareConfig{
+		Verifier: &x402go.EIP3009Verifier{},
+	}, app)
+
+	// Prices come from the file named by PRICES if set, e.g. prices.yaml:
+	//
+	//	routes:
+	//	  - pattern: "GET /premium"
+	//	    accepts:
+	//	      - scheme: exact
+	//	        amount: "1000000"
+	//	        ...
+	if path := os.Getenv("PRICES"); path != "" {
+		prices, err := x402go.LoadPaywallConfig(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := paywall.HandleConfig(prices); err != nil {
+			log.Fatal(err)
+		}
+	} else if err := paywall.Handle("GET /premium", requirements); err != nil {
+		log.Fatal(err)
+	}
+
 	log.Println("Server starting on :8080")
 	log.Println("Try: curl http://localhost:8080/free")
 	log.Println("Try: curl http://localhost:8080/premium")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	log.Fatal(http.ListenAndServe(":8080", paywall))
 }
@@ -2,8 +2,12 @@ package x402go
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"time"
 )
 
 // Facilitator provides blockchain verification and settlement services
@@ -17,14 +21,14 @@ type Facilitator interface {
 
 // FacilitatorServer wraps a Facilitator with HTTP handlers
 type FacilitatorServer struct {
-	facilitator Facilitator
+	facilitator ContextFacilitator
 	mux         *http.ServeMux
 }
 
 // NewFacilitatorServer creates a new facilitator server
 func NewFacilitatorServer(facilitator Facilitator) *FacilitatorServer {
 	fs := &FacilitatorServer{
-		facilitator: facilitator,
+		facilitator: AsContextFacilitator(facilitator),
 		mux:         http.NewServeMux(),
 	}
 
@@ -48,24 +52,62 @@ func (fs *FacilitatorServer) handleVerify(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if isRequestV1(body) {
+		fs.handleVerifyV1(w, r, body)
+		return
+	}
+
 	var req VerifyRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := json.Unmarshal(body, &req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	resp, err := fs.facilitator.Verify(&req)
+	resp, err := fs.facilitator.VerifyContext(r.Context(), &req)
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": err.Error(),
-		})
+		writeFacilitatorError(w, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	writeJSON(w, resp)
+}
+
+// handleVerifyV1 handles a v1 /verify request body
+func (fs *FacilitatorServer) handleVerifyV1(w http.ResponseWriter, r *http.Request, body []byte) {
+	var req FacilitatorRequestV1
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	payment, requirements := req.Decode()
+	resp, err := fs.facilitator.VerifyContext(r.Context(), &VerifyRequest{
+		TxHash:       payment.TxHash,
+		Chain:        payment.Chain,
+		Payment:      payment,
+		Requirements: requirements,
+	})
+	if err != nil {
+		writeFacilitatorError(w, err)
+		return
+	}
+
+	payer := resp.Sender
+	if payer == "" {
+		payer = payment.Sender
+	}
+
+	writeJSON(w, &VerifyResponseV1{
+		IsValid:       resp.Valid,
+		InvalidReason: resp.Error,
+		Payer:         payer,
+	})
 }
 
 // handleSettle handles POST /settle requests
@@ -75,31 +117,81 @@ func (fs *FacilitatorServer) handleSettle(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if isRequestV1(body) {
+		fs.handleSettleV1(w, r, body)
+		return
+	}
+
 	var req SettleRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := json.Unmarshal(body, &req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	resp, err := fs.facilitator.Settle(&req)
+	resp, err := fs.facilitator.SettleContext(r.Context(), &req)
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": err.Error(),
-		})
+		writeFacilitatorError(w, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	writeJSON(w, resp)
+}
+
+// handleSettleV1 handles a v1 /settle request body
+func (fs *FacilitatorServer) handleSettleV1(w http.ResponseWriter, r *http.Request, body []byte) {
+	var req FacilitatorRequestV1
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	payment, requirements := req.Decode()
+	resp, err := fs.facilitator.SettleContext(r.Context(), &SettleRequest{
+		Payment:      *payment,
+		Amount:       req.Amount,
+		Requirements: requirements,
+	})
+	if err != nil {
+		writeFacilitatorError(w, err)
+		return
+	}
+
+	writeJSON(w, resp.ToV1(payment))
 }
 
 // handleHealth handles GET /health requests
 func (fs *FacilitatorServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]string{
+		"status": "ok",
+	})
+}
+
+// isRequestV1 reports whether a facilitator request body uses the v1 format
+func isRequestV1(body []byte) bool {
+	var probe struct {
+		X402Version int `json:"x402Version"`
+	}
+	return json.Unmarshal(body, &probe) == nil && probe.X402Version != 0
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeFacilitatorError writes a facilitator failure as a JSON 500 response
+func writeFacilitatorError(w http.ResponseWriter, err error) {
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusInternalServerError)
 	json.NewEncoder(w).Encode(map[string]string{
-		"status": "ok",
+		"error": err.Error(),
 	})
 }
 
@@ -107,30 +199,63 @@ func (fs *FacilitatorServer) handleHealth(w http.ResponseWriter, r *http.Request
 type FacilitatorClient struct {
 	baseURL    string
 	httpClient *http.Client
+
+	// WireFormat selects the request format (default: WireFormatV1). Requests
+	// without both Payment and Requirements are always sent in the legacy format.
+	WireFormat WireFormat
 }
 
-// NewFacilitatorClient creates a new facilitator client
+const (
+	// defaultVerifyTimeout bounds verify calls made without a deadline
+	defaultVerifyTimeout = 30 * time.Second
+
+	// defaultSettleTimeout bounds settle calls made without a deadline. Settling waits for
+	// transactions to be mined, up to three of them for escrowed payments, so it is longer
+	// than the evm facilitator's default ReceiptTimeout, which bounds all of them together.
+	defaultSettleTimeout = 3 * time.Minute
+)
+
+// NewFacilitatorClient creates a new facilitator client. Calls made without a deadline
+// time out after 30 seconds for verify and 3 minutes for settle.
 func NewFacilitatorClient(baseURL string) *FacilitatorClient {
+	return NewFacilitatorClientWithHTTPClient(baseURL, &http.Client{})
+}
+
+// NewFacilitatorClientWithHTTPClient creates a facilitator client that sends requests with httpClient
+func NewFacilitatorClientWithHTTPClient(baseURL string, httpClient *http.Client) *FacilitatorClient {
 	return &FacilitatorClient{
 		baseURL:    baseURL,
-		httpClient: &http.Client{},
+		httpClient: httpClient,
 	}
 }
 
 // Verify verifies a payment transaction
 func (fc *FacilitatorClient) Verify(req *VerifyRequest) (*VerifyResponse, error) {
-	resp, err := fc.httpClient.Post(
-		fc.baseURL+"/verify",
-		"application/json",
-		toReader(req),
-	)
-	if err != nil {
-		return nil, err
+	return fc.VerifyContext(context.Background(), req)
+}
+
+// VerifyContext verifies a payment transaction within ctx
+func (fc *FacilitatorClient) VerifyContext(ctx context.Context, req *VerifyRequest) (*VerifyResponse, error) {
+	ctx, cancel := withDefaultTimeout(ctx, defaultVerifyTimeout)
+	defer cancel()
+
+	if fc.useV1(req.Payment, req.Requirements) {
+		var v1Resp VerifyResponseV1
+		if err := fc.post(ctx, "/verify", NewFacilitatorRequestV1(req.Payment, req.Requirements), &v1Resp); err != nil {
+			return nil, err
+		}
+
+		return &VerifyResponse{
+			Valid:  v1Resp.IsValid,
+			TxHash: req.Payment.TxHash,
+			Chain:  req.Payment.Chain,
+			Sender: v1Resp.Payer,
+			Error:  v1Resp.InvalidReason,
+		}, nil
 	}
-	defer resp.Body.Close()
 
 	var verifyResp VerifyResponse
-	if err := json.NewDecoder(resp.Body).Decode(&verifyResp); err != nil {
+	if err := fc.post(ctx, "/verify", req, &verifyResp); err != nil {
 		return nil, err
 	}
 
@@ -139,24 +264,80 @@ func (fc *FacilitatorClient) Verify(req *VerifyRequest) (*VerifyResponse, error)
 
 // Settle settles a payment
 func (fc *FacilitatorClient) Settle(req *SettleRequest) (*SettleResponse, error) {
-	resp, err := fc.httpClient.Post(
-		fc.baseURL+"/settle",
-		"application/json",
-		toReader(req),
-	)
-	if err != nil {
-		return nil, err
+	return fc.SettleContext(context.Background(), req)
+}
+
+// SettleContext settles a payment within ctx
+func (fc *FacilitatorClient) SettleContext(ctx context.Context, req *SettleRequest) (*SettleResponse, error) {
+	ctx, cancel := withDefaultTimeout(ctx, defaultSettleTimeout)
+	defer cancel()
+
+	if fc.useV1(&req.Payment, req.Requirements) {
+		v1Req := NewFacilitatorRequestV1(&req.Payment, req.Requirements)
+		v1Req.Amount = req.Amount
+
+		var v1Resp SettlementResponseV1
+		if err := fc.post(ctx, "/settle", v1Req, &v1Resp); err != nil {
+			return nil, err
+		}
+
+		return &SettleResponse{
+			Settled:   v1Resp.Success,
+			TxHash:    v1Resp.Transaction,
+			Error:     v1Resp.ErrorReason,
+			Timestamp: time.Now().Unix(),
+		}, nil
 	}
-	defer resp.Body.Close()
 
 	var settleResp SettleResponse
-	if err := json.NewDecoder(resp.Body).Decode(&settleResp); err != nil {
+	if err := fc.post(ctx, "/settle", req, &settleResp); err != nil {
 		return nil, err
 	}
 
 	return &settleResp, nil
 }
 
+// withDefaultTimeout bounds ctx by timeout unless it already has a deadline
+func withDefaultTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// useV1 reports whether a request can be sent in the v1 format
+func (fc *FacilitatorClient) useV1(payment *Payment, requirements *PaymentRequirements) bool {
+	return fc.WireFormat == WireFormatV1 && payment != nil && requirements != nil
+}
+
+// post sends v as JSON to the facilitator endpoint at path and decodes the response into out
+func (fc *FacilitatorClient) post(ctx context.Context, path string, v, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fc.baseURL+path, toReader(v))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := fc.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Facilitator failures are reported as errors, not as invalid payments
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&errResp) == nil && errResp.Error != "" {
+			return fmt.Errorf("facilitator returned %s: %s", resp.Status, errResp.Error)
+		}
+		return fmt.Errorf("facilitator returned %s", resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
 // toReader converts any value to an io.Reader containing its JSON representation
 func toReader(v interface{}) *bytes.Reader {
 	data, _ := json.Marshal(v)
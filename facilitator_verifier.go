@@ -0,0 +1,109 @@
+package x402go
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/berhberhberh/x402go/internal/parse"
+)
+
+// FacilitatorError reports that a facilitator could not be reached or failed to
+// process a request, as opposed to judging the payment invalid
+type FacilitatorError struct {
+	Err error
+}
+
+// Error implements error
+func (e *FacilitatorError) Error() string {
+	return "facilitator error: " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *FacilitatorError) Unwrap() error {
+	return e.Err
+}
+
+// FacilitatorVerifier is a PaymentVerifier that delegates verification to a Facilitator
+// and cross-checks the facilitator's findings against the payment requirements
+type FacilitatorVerifier struct {
+	// Facilitator verifies payments (optional). When nil, a FacilitatorClient for the
+	// URL advertised in PaymentRequirements.Facilitator is used.
+	Facilitator Facilitator
+}
+
+// NewFacilitatorVerifier creates a verifier backed by facilitator
+func NewFacilitatorVerifier(facilitator Facilitator) *FacilitatorVerifier {
+	return &FacilitatorVerifier{
+		Facilitator: facilitator,
+	}
+}
+
+// Verify implements PaymentVerifier
+func (v *FacilitatorVerifier) Verify(payment *Payment, requirements *PaymentRequirements) (bool, error) {
+	return v.VerifyContext(context.Background(), payment, requirements)
+}
+
+// VerifyContext implements ContextPaymentVerifier. Failures to reach or use the
+// facilitator are returned as *FacilitatorError.
+func (v *FacilitatorVerifier) VerifyContext(ctx context.Context, payment *Payment, requirements *PaymentRequirements) (bool, error) {
+	facilitator := v.Facilitator
+	if facilitator == nil {
+		if requirements.Facilitator == "" {
+			return false, &FacilitatorError{Err: fmt.Errorf("no facilitator configured")}
+		}
+		facilitator = NewFacilitatorClient(requirements.Facilitator)
+	}
+
+	resp, err := AsContextFacilitator(facilitator).VerifyContext(ctx, &VerifyRequest{
+		TxHash:       payment.TxHash,
+		Chain:        payment.Chain,
+		Payment:      payment,
+		Requirements: requirements,
+	})
+	if err != nil {
+		return false, &FacilitatorError{Err: err}
+	}
+	if !resp.Valid {
+		return false, nil
+	}
+
+	return checkVerifyResponse(resp, payment, requirements)
+}
+
+// checkVerifyResponse checks the details a facilitator reported for a payment against the
+// payment and its requirements. Fields the facilitator left empty are not checked.
+func checkVerifyResponse(resp *VerifyResponse, payment *Payment, requirements *PaymentRequirements) (bool, error) {
+	if resp.TxHash != "" && payment.TxHash != "" && !strings.EqualFold(resp.TxHash, payment.TxHash) {
+		return false, nil
+	}
+	if resp.Chain != "" && ChainForNetwork(resp.Chain) != ChainForNetwork(requirements.Chain) {
+		return false, nil
+	}
+	if resp.Token != "" && !strings.EqualFold(resp.Token, requirements.Token) {
+		return false, nil
+	}
+	// Escrowed "upto" payments are authorized to the escrow, which forwards the charge
+	if resp.Recipient != "" && !strings.EqualFold(resp.Recipient, authorizationRecipient(requirements)) {
+		return false, nil
+	}
+	if resp.Sender != "" && payment.Sender != "" && !strings.EqualFold(resp.Sender, payment.Sender) {
+		return false, nil
+	}
+
+	if resp.Amount != "" {
+		paid, err := parse.Uint(resp.Amount, "amount")
+		if err != nil {
+			return false, &FacilitatorError{Err: err}
+		}
+		required, err := parse.Uint(requirements.Amount, "amount")
+		if err != nil {
+			return false, err
+		}
+		if paid.Cmp(required) < 0 {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
@@ -0,0 +1,42 @@
+package x402go
+
+import (
+	"testing"
+)
+
+func TestCheckVerifyResponse(t *testing.T) {
+	const (
+		recipient = "0x1111111111111111111111111111111111111111"
+		escrow    = "0x2222222222222222222222222222222222222222"
+	)
+
+	exact := &PaymentRequirements{Scheme: SchemeExact, Amount: "1000", Chain: "8453", Recipient: recipient}
+	upto := &PaymentRequirements{Scheme: SchemeUpTo, Amount: "1000", Chain: "8453", Recipient: recipient, Extra: map[string]interface{}{escrowExtraKey: escrow}}
+
+	tests := []struct {
+		name         string
+		resp         *VerifyResponse
+		requirements *PaymentRequirements
+		want         bool
+	}{
+		{"nothing reported", &VerifyResponse{Valid: true}, exact, true},
+		{"exact to recipient", &VerifyResponse{Valid: true, Recipient: recipient, Amount: "1000"}, exact, true},
+		{"exact to other", &VerifyResponse{Valid: true, Recipient: escrow}, exact, false},
+		{"upto to escrow", &VerifyResponse{Valid: true, Recipient: escrow, Amount: "1000"}, upto, true},
+		{"upto bypassing escrow", &VerifyResponse{Valid: true, Recipient: recipient}, upto, false},
+		{"underpaid", &VerifyResponse{Valid: true, Amount: "999"}, exact, false},
+		{"other chain", &VerifyResponse{Valid: true, Chain: "1"}, exact, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := checkVerifyResponse(tt.resp, &Payment{}, tt.requirements)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("checkVerifyResponse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
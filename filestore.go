@@ -0,0 +1,148 @@
+package x402go
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// jsonFile is the JSON file behind a file-backed store. The store keeps its state in
+// memory and commits it to the file after every change.
+type jsonFile struct {
+	path string
+
+	// name describes the store in errors, e.g. "balance store"
+	name string
+}
+
+// load reads the file into v. A missing or empty file leaves v as it is.
+func (f *jsonFile) load(v interface{}) error {
+	data, err := os.ReadFile(f.path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", f.name, err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, v); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
+// commit writes v, the state after a change, replacing the previous file atomically. If
+// it cannot be written, undo reverts the change in memory so the store never reports a
+// change it did not persist.
+func (f *jsonFile) commit(v interface{}, undo func()) error {
+	data, err := json.Marshal(v)
+	if err == nil {
+		err = writeFileAtomic(f.path, data)
+	}
+	if err != nil {
+		undo()
+		return fmt.Errorf("failed to save %s: %w", f.name, err)
+	}
+	return nil
+}
+
+// minLogCompaction is the fewest records an append log is compacted at
+const minLogCompaction = 1024
+
+// appendLog is the file behind a store persisted as an append-only log of JSON records,
+// one per line. The store keeps its state in memory, appends a record for every change
+// and rewrites the log from its state each time the log doubles in size.
+type appendLog struct {
+	path string
+
+	// name describes the store in errors, e.g. "nonce store"
+	name string
+
+	file *os.File
+
+	// records is the number of records in the log, compacted the number it held after
+	// the last compaction
+	records   int
+	compacted int
+}
+
+// replay reads the log and passes each record to apply, which fails if the record does
+// not parse. A missing file holds no records.
+func (l *appendLog) replay(apply func(record []byte) error) error {
+	data, err := os.ReadFile(l.path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", l.name, err)
+	}
+
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if err := apply(line); err != nil {
+			// The last record may have been torn by a crash while it was appended
+			if i == len(lines)-1 {
+				return nil
+			}
+			return fmt.Errorf("failed to parse %s: %w", l.name, err)
+		}
+	}
+	return nil
+}
+
+// append writes a record to the log, syncing it to disk if sync is set
+func (l *appendLog) append(record interface{}, sync bool) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := l.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write %s: %w", l.name, err)
+	}
+	l.records++
+	if sync {
+		if err := l.file.Sync(); err != nil {
+			return fmt.Errorf("failed to sync %s: %w", l.name, err)
+		}
+	}
+	return nil
+}
+
+// sparse reports whether the log has grown to twice its size after the last compaction
+func (l *appendLog) sparse() bool {
+	return l.records >= max(minLogCompaction, 2*l.compacted)
+}
+
+// compact replaces the log with records and reopens it for appending. It also repairs
+// the log after a failed append, so a partly written record cannot corrupt the records
+// appended after it.
+func (l *appendLog) compact(records []interface{}) error {
+	var buf bytes.Buffer
+	for _, record := range records {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	if err := writeFileAtomic(l.path, buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to compact %s: %w", l.name, err)
+	}
+
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", l.name, err)
+	}
+	if l.file != nil {
+		l.file.Close()
+	}
+	l.file = file
+	l.records = len(records)
+	l.compacted = l.records
+	return nil
+}
+
+// close closes the log file
+func (l *appendLog) close() error {
+	return l.file.Close()
+}
@@ -1,15 +1,36 @@
 package x402go
 
 const (
-	// HeaderPayment is the header key for payment requirements (server to client)
+	// HeaderPayment is the header key for payment requirements (server to client) in the
+	// legacy format. In the v1 format the same header carries the payment payload (client to server).
 	HeaderPayment = "X-Payment"
 
-	// HeaderPaymentResponse is the header key for payment payload (client to server)
+	// HeaderPaymentResponse is the header key for payment payload (client to server) in the
+	// legacy format. In the v1 format the same header carries the settlement response (server to client).
 	HeaderPaymentResponse = "X-Payment-Response"
 
+	// HeaderPaymentSession carries the session token of a prepaid balance, issued in the
+	// response to a deposit and presented by the client on later requests
+	HeaderPaymentSession = "X-Payment-Session"
+
+	// HeaderPaymentPass carries an access pass token, issued in the response to a payment
+	// for a pass and presented by the client on later requests within the pass's scope
+	HeaderPaymentPass = "X-Payment-Pass"
+
+	// HeaderPaymentError carries the RejectionReason of a rejected payment, so clients can
+	// tell a stale quote from a payment that will never be accepted
+	HeaderPaymentError = "X-Payment-Error"
+
 	// HeaderWWWAuthenticate is used with 402 status code
 	HeaderWWWAuthenticate = "WWW-Authenticate"
 
 	// SchemeExact represents the "exact" payment scheme
 	SchemeExact = "exact"
+
+	// SchemeUpTo represents the "upto" payment scheme: the client authorizes the required
+	// amount as a maximum and is charged only for what the handler reports as used
+	SchemeUpTo = "upto"
+
+	// X402Version is the protocol version spoken by the v1 wire format
+	X402Version = 1
 )
@@ -0,0 +1,34 @@
+// Package parse parses the addresses and integers carried in payments and payment
+// requirements, shared by x402go and its facilitators.
+package parse
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Address parses a hex address
+func Address(s string) (common.Address, error) {
+	if !common.IsHexAddress(s) {
+		return common.Address{}, fmt.Errorf("invalid address %q", s)
+	}
+	return common.HexToAddress(s), nil
+}
+
+// Uint parses a non-negative decimal or 0x-prefixed hex integer, naming field in the error
+func Uint(s, field string) (*big.Int, error) {
+	var n *big.Int
+	var ok bool
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		n, ok = new(big.Int).SetString(s[2:], 16)
+	} else {
+		n, ok = new(big.Int).SetString(s, 10)
+	}
+	if !ok || n.Sign() < 0 {
+		return nil, fmt.Errorf("invalid %s %q", field, s)
+	}
+	return n, nil
+}
@@ -0,0 +1,53 @@
+package parse
+
+import "testing"
+
+func TestUint(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"0", "0", false},
+		{"1000", "1000", false},
+		{"0x3e8", "1000", false},
+		{"0X3E8", "1000", false},
+		{"115792089237316195423570985008687907853269984665640564039457584007913129639935", "115792089237316195423570985008687907853269984665640564039457584007913129639935", false},
+		{"", "", true},
+		{"-1", "", true},
+		{"0x", "", true},
+		{"0x-1", "", true},
+		{"1.5", "", true},
+		{"1e3", "", true},
+		{" 1", "", true},
+	}
+	for _, tt := range tests {
+		n, err := Uint(tt.in, "value")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Uint(%q) err = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err == nil && n.String() != tt.want {
+			t.Errorf("Uint(%q) = %s, want %s", tt.in, n, tt.want)
+		}
+	}
+}
+
+func TestAddress(t *testing.T) {
+	tests := []struct {
+		in      string
+		wantErr bool
+	}{
+		{"0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913", false},
+		{"0x833589fcd6edb6e08f4c7c32d4f71b54bda02913", false},
+		{"833589fCD6eDb6E08f4c7C32D4f71b54bdA02913", false},
+		{"0x833589fCD6eDb6E08f4c7C32D4f71b54bdA0291", true},
+		{"0xYourAddress", true},
+		{"", true},
+	}
+	for _, tt := range tests {
+		if _, err := Address(tt.in); (err != nil) != tt.wantErr {
+			t.Errorf("Address(%q) err = %v, want error %v", tt.in, err, tt.wantErr)
+		}
+	}
+}
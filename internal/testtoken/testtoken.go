@@ -0,0 +1,395 @@
+// Package testtoken deploys a minimal EIP-3009 token for tests against go-ethereum's
+// simulated backend.
+//
+// The token is written in EVM assembly and compiled with go-ethereum's core/asm, so tests
+// need no Solidity toolchain. It keeps balances, lets anyone mint, emits ERC-20 Transfer
+// events and checks transferWithAuthorization signatures against its EIP-712 domain
+// (Name, Version, the chain ID and its own address). It does not implement allowances,
+// cancelAuthorization or signature malleability checks.
+package testtoken
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/asm"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	// Name is the token's EIP-712 domain name
+	Name = "Test Token"
+
+	// Version is the token's EIP-712 domain version
+	Version = "1"
+)
+
+// tokenABI is the interface of the token
+const tokenABI = `[{
+	"type": "function",
+	"name": "transfer",
+	"stateMutability": "nonpayable",
+	"inputs": [
+		{"name": "to", "type": "address"},
+		{"name": "value", "type": "uint256"}
+	],
+	"outputs": [{"name": "", "type": "bool"}]
+}, {
+	"type": "function",
+	"name": "transferWithAuthorization",
+	"stateMutability": "nonpayable",
+	"inputs": [
+		{"name": "from", "type": "address"},
+		{"name": "to", "type": "address"},
+		{"name": "value", "type": "uint256"},
+		{"name": "validAfter", "type": "uint256"},
+		{"name": "validBefore", "type": "uint256"},
+		{"name": "nonce", "type": "bytes32"},
+		{"name": "v", "type": "uint8"},
+		{"name": "r", "type": "bytes32"},
+		{"name": "s", "type": "bytes32"}
+	],
+	"outputs": []
+}, {
+	"type": "function",
+	"name": "balanceOf",
+	"stateMutability": "view",
+	"inputs": [{"name": "account", "type": "address"}],
+	"outputs": [{"name": "", "type": "uint256"}]
+}, {
+	"type": "function",
+	"name": "mint",
+	"stateMutability": "nonpayable",
+	"inputs": [
+		{"name": "to", "type": "address"},
+		{"name": "value", "type": "uint256"}
+	],
+	"outputs": []
+}]`
+
+// runtimeSource is the token's runtime code. Balances are stored at the slot of the
+// account's address and used authorizations at keccak256(from . nonce). The $-prefixed
+// constants are substituted before compiling.
+const runtimeSource = `
+;; Dispatch on the function selector
+PUSH 0
+CALLDATALOAD
+PUSH 224
+SHR
+DUP1
+PUSH $transfer
+EQ
+JUMPI @transfer
+DUP1
+PUSH $transferWithAuthorization
+EQ
+JUMPI @transferWithAuthorization
+DUP1
+PUSH $balanceOf
+EQ
+JUMPI @balanceOf
+DUP1
+PUSH $mint
+EQ
+JUMPI @mint
+revert:
+PUSH 0
+DUP1
+REVERT
+
+;; balanceOf(account)
+balanceOf:
+PUSH 4
+CALLDATALOAD
+SLOAD
+PUSH 0
+MSTORE
+PUSH 32
+PUSH 0
+RETURN
+
+;; mint(to, value)
+mint:
+PUSH 36
+CALLDATALOAD
+PUSH 4
+CALLDATALOAD
+DUP1
+SLOAD
+DUP3
+ADD
+SWAP1
+SSTORE
+STOP
+
+;; transfer(to, value) moves value from the caller
+transfer:
+PUSH @returnTrue
+PUSH 36
+CALLDATALOAD
+PUSH 4
+CALLDATALOAD
+CALLER
+JUMP @move
+returnTrue:
+PUSH 1
+PUSH 0
+MSTORE
+PUSH 32
+PUSH 0
+RETURN
+
+;; transferWithAuthorization(from, to, value, validAfter, validBefore, nonce, v, r, s)
+transferWithAuthorization:
+;; validAfter < block.timestamp < validBefore
+PUSH 100
+CALLDATALOAD
+TIMESTAMP
+GT
+ISZERO
+JUMPI @revert
+TIMESTAMP
+PUSH 132
+CALLDATALOAD
+GT
+ISZERO
+JUMPI @revert
+
+;; Each nonce authorizes a single transfer from its signer
+PUSH 4
+CALLDATALOAD
+PUSH 0
+MSTORE
+PUSH 164
+CALLDATALOAD
+PUSH 32
+MSTORE
+PUSH 64
+PUSH 0
+KECCAK256
+DUP1
+SLOAD
+JUMPI @revert
+PUSH 1
+SWAP1
+SSTORE
+
+;; structHash = keccak256(typehash . from . to . value . validAfter . validBefore . nonce)
+PUSH $authorizationTypehash
+PUSH 0
+MSTORE
+PUSH 192
+PUSH 4
+PUSH 32
+CALLDATACOPY
+PUSH 224
+PUSH 0
+KECCAK256
+
+;; domainSeparator = keccak256(typehash . nameHash . versionHash . chainid . address)
+PUSH $domainTypehash
+PUSH 256
+MSTORE
+PUSH $nameHash
+PUSH 288
+MSTORE
+PUSH $versionHash
+PUSH 320
+MSTORE
+CHAINID
+PUSH 352
+MSTORE
+ADDRESS
+PUSH 384
+MSTORE
+PUSH 160
+PUSH 256
+KECCAK256
+
+;; digest = keccak256(0x1901 . domainSeparator . structHash)
+PUSH 2
+MSTORE
+PUSH 34
+MSTORE
+PUSH 0x19
+PUSH 0
+MSTORE8
+PUSH 0x01
+PUSH 1
+MSTORE8
+PUSH 66
+PUSH 0
+KECCAK256
+
+;; The signer recovered by ecrecover(digest, v, r, s) must be from
+PUSH 0
+MSTORE
+PUSH 96
+PUSH 196
+PUSH 32
+CALLDATACOPY
+PUSH 0
+PUSH 128
+MSTORE
+PUSH 32
+PUSH 128
+PUSH 128
+PUSH 0
+PUSH 1
+GAS
+STATICCALL
+POP
+PUSH 128
+MLOAD
+DUP1
+ISZERO
+JUMPI @revert
+PUSH 4
+CALLDATALOAD
+EQ
+ISZERO
+JUMPI @revert
+
+PUSH @stop
+PUSH 68
+CALLDATALOAD
+PUSH 36
+CALLDATALOAD
+PUSH 4
+CALLDATALOAD
+JUMP @move
+stop:
+STOP
+
+;; move takes from, to, value and a return address from the stack, moves value from
+;; from to to, emits Transfer and returns
+move:
+DUP1
+SLOAD
+DUP4
+DUP2
+DUP2
+GT
+JUMPI @revert
+SWAP1
+SUB
+DUP2
+SSTORE
+DUP2
+SLOAD
+DUP4
+ADD
+DUP3
+SSTORE
+DUP3
+PUSH 0
+MSTORE
+DUP2
+DUP2
+PUSH $transferEvent
+PUSH 32
+PUSH 0
+LOG3
+POP
+POP
+POP
+JUMP
+`
+
+// parsedABI is the parsed tokenABI
+var parsedABI = mustParseABI(tokenABI)
+
+// bytecode is the token's deployment code
+var bytecode = mustCompile()
+
+// Token is a deployed test token
+type Token struct {
+	// Address is the token contract address
+	Address common.Address
+
+	contract *bind.BoundContract
+}
+
+// Deploy submits the token's deployment transaction. The token is usable once the
+// transaction is mined, after a Commit on the simulated backend.
+func Deploy(opts *bind.TransactOpts, backend bind.ContractBackend) (*Token, error) {
+	address, _, contract, err := bind.DeployContract(opts, parsedABI, bytecode, backend)
+	if err != nil {
+		return nil, fmt.Errorf("failed to deploy test token: %w", err)
+	}
+	return &Token{Address: address, contract: contract}, nil
+}
+
+// Mint credits value to an account
+func (t *Token) Mint(opts *bind.TransactOpts, to common.Address, value *big.Int) (*types.Transaction, error) {
+	return t.contract.Transact(opts, "mint", to, value)
+}
+
+// Transfer moves value from the sender of opts to an account
+func (t *Token) Transfer(opts *bind.TransactOpts, to common.Address, value *big.Int) (*types.Transaction, error) {
+	return t.contract.Transact(opts, "transfer", to, value)
+}
+
+// BalanceOf returns the balance of an account
+func (t *Token) BalanceOf(opts *bind.CallOpts, account common.Address) (*big.Int, error) {
+	var out []interface{}
+	if err := t.contract.Call(opts, &out, "balanceOf", account); err != nil {
+		return nil, err
+	}
+	return out[0].(*big.Int), nil
+}
+
+// mustCompile compiles runtimeSource and prefixes it with code deploying it
+func mustCompile() []byte {
+	keccak := func(s string) string { return crypto.Keccak256Hash([]byte(s)).Hex() }
+	selector := func(method string) string { return hexNumber(parsedABI.Methods[method].ID) }
+
+	source := strings.NewReplacer(
+		"$transferWithAuthorization", selector("transferWithAuthorization"),
+		"$transferEvent", keccak("Transfer(address,address,uint256)"),
+		"$transfer", selector("transfer"),
+		"$balanceOf", selector("balanceOf"),
+		"$mint", selector("mint"),
+		"$authorizationTypehash", keccak("TransferWithAuthorization(address from,address to,uint256 value,uint256 validAfter,uint256 validBefore,bytes32 nonce)"),
+		"$domainTypehash", keccak("EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)"),
+		"$nameHash", keccak(Name),
+		"$versionHash", keccak(Version),
+	).Replace(runtimeSource)
+
+	compiler := asm.NewCompiler(false)
+	compiler.Feed(asm.Lex([]byte(source), false))
+	out, errs := compiler.Compile()
+	if len(errs) > 0 {
+		panic(fmt.Sprintf("failed to compile test token: %v", errs))
+	}
+	runtime, err := hex.DecodeString(out)
+	if err != nil {
+		panic(err)
+	}
+
+	// PUSH2 len DUP1 PUSH1 12 PUSH1 0 CODECOPY PUSH1 0 RETURN, copying the runtime code
+	// that follows these 12 bytes into memory and returning it
+	size := len(runtime)
+	deploy := []byte{0x61, byte(size >> 8), byte(size), 0x80, 0x60, 0x0c, 0x60, 0x00, 0x39, 0x60, 0x00, 0xf3}
+	return append(deploy, runtime...)
+}
+
+// hexNumber formats b as a 0x-prefixed hex number
+func hexNumber(b []byte) string {
+	return "0x" + hex.EncodeToString(b)
+}
+
+// mustParseABI parses a JSON ABI definition, panicking on error
+func mustParseABI(definition string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(definition))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
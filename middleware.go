@@ -3,10 +3,16 @@ package x402go
 import (
 	"context"
 	"crypto/rand"
-	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
 	"net/http"
+	"strings"
 	"time"
+
+	"github.com/berhberhberh/x402go/internal/parse"
 )
 
 // PaymentContextKey is the context key for storing payment information
@@ -21,25 +27,90 @@ type PaymentVerifier interface {
 	Verify(payment *Payment, requirements *PaymentRequirements) (bool, error)
 }
 
+// ErrPaymentPending is returned by verifiers when a payment exists but is not final yet.
+// The middleware asks the client to retry with the same payment later.
+var ErrPaymentPending = errors.New("payment pending confirmation")
+
+// pendingRetryAfter is the Retry-After value, in seconds, sent for pending payments
+const pendingRetryAfter = "5"
+
+// RequirementsFunc prices a request, returning the payment options it can be paid with.
+// The first option is the preferred one.
+type RequirementsFunc func(r *http.Request) ([]*PaymentRequirements, error)
+
 // MiddlewareConfig holds configuration for payment middleware
 type MiddlewareConfig struct {
 	// Requirements defines the payment requirements
 	Requirements *PaymentRequirements
 
-	// Verifier is used to verify payments (optional, uses default if nil)
+	// Accepts lists further payment options, e.g. other tokens or chains (optional).
+	// Clients may pay with Requirements or any of these.
+	Accepts []*PaymentRequirements
+
+	// RequirementsFunc prices each request (optional). When set, it replaces Requirements
+	// and Accepts. Payments are verified against the options quoted for their nonce.
+	RequirementsFunc RequirementsFunc
+
+	// Verifier is used to verify payments (optional, uses default if nil). Verifiers
+	// implementing ContextPaymentVerifier receive the request's context.
 	Verifier PaymentVerifier
 
 	// OnPaymentVerified is called when a payment is successfully verified
 	OnPaymentVerified func(payment *Payment, r *http.Request)
 
-	// OnPaymentRequired is called when payment is required but not provided
-	OnPaymentRequired func(w http.ResponseWriter, r *http.Request)
+	// OnPaymentRequired is called when payment is required but not provided, with the
+	// requirements issued for the request, the preferred one first. It writes the 402
+	// response in place of the default one. The requirements' nonce is already recorded,
+	// so payments made against them are accepted.
+	OnPaymentRequired func(w http.ResponseWriter, r *http.Request, issued []*PaymentRequirements)
+
+	// OnPaymentRejected is called when a payment is rejected and writes the response in
+	// place of the default one, which rejection.WriteResponse produces
+	OnPaymentRejected func(w http.ResponseWriter, r *http.Request, rejection *PaymentRejection)
 
 	// NonceGenerator generates unique nonces (optional)
 	NonceGenerator func() string
 
 	// ExpiryDuration sets how long payment requirements are valid (default: 5 minutes)
 	ExpiryDuration time.Duration
+
+	// WireFormat selects the format of 402 responses (default: WireFormatV1).
+	// Payments are accepted in either format.
+	WireFormat WireFormat
+
+	// NonceStore tracks issued nonces so each payment can be redeemed once
+	// (optional, defaults to an in-memory store). Every 402 response carries a freshly
+	// generated nonce, so a Nonce set on Requirements is ignored.
+	NonceStore NonceStore
+
+	// QuoteSigner signs the quote in each 402 response (optional). When set, payments are
+	// verified against the signed quote the client echoes back instead of a quote stored
+	// with the nonce, and the NonceStore only records redeemed nonces to reject replays.
+	QuoteSigner *QuoteSigner
+
+	// Settlement selects when verified payments are settled (default: SettleNone)
+	Settlement SettlementMode
+
+	// Facilitator settles payments (optional). When nil and Settlement is enabled, a
+	// FacilitatorClient for the first facilitator URL among the options is used. Facilitators
+	// implementing ContextFacilitator receive the request's context.
+	Facilitator Facilitator
+
+	// OnSettlementError is called when a payment fails to settle
+	OnSettlementError func(payment *Payment, err error)
+
+	// Credits enables deposit mode, where payments credit a prepaid balance that later
+	// requests are debited from (optional, requires Settlement)
+	Credits *CreditConfig
+
+	// Paywall customizes the HTML page sent to browsers instead of the JSON 402 body
+	// (optional, defaults to a built-in page)
+	Paywall *PaywallPage
+
+	// PassKey signs the access passes sold by options with Pass terms. It must be a secret
+	// of at least 16 bytes shared by every server instance that accepts the passes, and is
+	// required when any option sells a pass.
+	PassKey []byte
 }
 
 // RequirePayment creates HTTP middleware that requires payment before processing requests
@@ -52,9 +123,21 @@ func RequirePayment(requirements *PaymentRequirements, next http.Handler) http.H
 
 // RequirePaymentWithConfig creates HTTP middleware with custom configuration
 func RequirePaymentWithConfig(config *MiddlewareConfig, next http.Handler) http.Handler {
-	if config.Requirements == nil {
+	options := config.options()
+	if len(options) == 0 && config.RequirementsFunc == nil {
 		panic("payment requirements cannot be nil")
 	}
+	for _, option := range options {
+		if err := option.Validate(); err != nil {
+			panic("invalid payment requirements: " + err.Error())
+		}
+		if option.Pass != nil && len(config.PassKey) == 0 {
+			panic("payment requirements sell a pass but no pass key is configured")
+		}
+	}
+	if len(config.PassKey) > 0 && len(config.PassKey) < minTokenKeyLength {
+		panic(fmt.Sprintf("pass key must be at least %d bytes", minTokenKeyLength))
+	}
 
 	// Set defaults
 	if config.NonceGenerator == nil {
@@ -66,87 +149,522 @@ func RequirePaymentWithConfig(config *MiddlewareConfig, next http.Handler) http.
 	if config.Verifier == nil {
 		config.Verifier = &DefaultVerifier{}
 	}
+	if config.Credits != nil {
+		if config.Settlement == SettleNone {
+			panic("deposit mode requires settlement, or deposits are credited without being paid")
+		}
+		config.Credits.init()
+	}
+	if config.NonceStore == nil {
+		config.NonceStore = NewMemoryNonceStore()
+	}
+	if config.Settlement != SettleNone && config.Facilitator == nil {
+		url := facilitatorURL(options)
+		if url == "" {
+			panic("settlement requires a facilitator")
+		}
+		config.Facilitator = NewFacilitatorClient(url)
+	}
+
+	// Verification runs under the request's context when the verifier supports it
+	verifier := AsContextVerifier(config.Verifier)
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Check if payment response header is present
-		paymentHeader := r.Header.Get(HeaderPaymentResponse)
+		// Read the payment from whichever header the client used
+		payment, err := readPayment(r)
+		if err != nil {
+			rejectPayment(w, r, config, &PaymentRejection{
+				Reason:  RejectBadFormat,
+				Err:     err,
+				Status:  http.StatusBadRequest,
+				Message: "Invalid payment format",
+			})
+			return
+		}
+
+		if payment == nil {
+			// Serve under an access pass if the client presents one covering the request
+			if pass := readPass(r, config); pass != nil {
+				servePass(w, r, pass, next)
+				return
+			}
+
+			// Serve from a prepaid balance if the client presents a session covering the request
+			if config.Credits != nil {
+				if session := config.Credits.readSession(r); session != nil && serveFromSession(w, r, config, session, next) {
+					return
+				}
+			}
 
-		if paymentHeader == "" {
 			// No payment provided, return 402 with requirements
-			sendPaymentRequired(w, config)
+			sendPaymentRequired(w, r, config)
 			return
 		}
 
-		// Parse payment from header
-		var payment Payment
-		if err := json.Unmarshal([]byte(paymentHeader), &payment); err != nil {
-			http.Error(w, "Invalid payment format", http.StatusBadRequest)
+		// Look up what was quoted for the payment's nonce. Payments from other v1 clients
+		// carry a nonce of their own choosing and are priced afresh instead.
+		quote, err := lookupQuote(config, payment)
+		foreign := false
+		if err != nil && isForeignPayment(r, config, payment, err) {
+			quote, err = quoteRequest(r, config)
+			foreign = true
+		}
+		if err != nil {
+			if isRejection(err) {
+				rejectPayment(w, r, config, nonceRejection(payment, err))
+				return
+			}
+			http.Error(w, "Failed to look up payment nonce", http.StatusInternalServerError)
+			return
+		}
+		if quote.Resource != "" && quote.Resource != resourceURL(r) {
+			rejectPayment(w, r, config, &PaymentRejection{
+				Reason:  RejectWrongResource,
+				Payment: payment,
+				Status:  http.StatusPaymentRequired,
+				Message: "Payment rejected: nonce was quoted for a different resource",
+			})
+			return
+		}
+
+		// The nonce must be remembered for as long as the authorization can be redeemed
+		if err := checkAuthorizationLifetime(payment, quote); err != nil {
+			rejectPayment(w, r, config, &PaymentRejection{
+				Reason:  RejectVerificationFailed,
+				Payment: payment,
+				Err:     err,
+				Status:  http.StatusPaymentRequired,
+				Message: "Payment rejected: " + err.Error(),
+			})
 			return
 		}
 
-		// Verify payment
-		valid, err := config.Verifier.Verify(&payment, config.Requirements)
+		// Quotes recorded without options predate per-request pricing
+		quoted := quote.Options
+		if len(quoted) == 0 {
+			quoted = options
+		}
+
+		// Verify payment against the quoted option it claims to pay
+		payment, accepted, err := verifyPayment(r.Context(), verifier, payment, quoted)
+		var facilitatorErr *FacilitatorError
+		if errors.As(err, &facilitatorErr) {
+			rejectPayment(w, r, config, &PaymentRejection{
+				Reason:  RejectFacilitatorError,
+				Payment: payment,
+				Err:     err,
+				Status:  http.StatusBadGateway,
+				Message: "Payment facilitator unavailable",
+			})
+			return
+		}
+		if errors.Is(err, ErrPaymentPending) {
+			w.Header().Set("Retry-After", pendingRetryAfter)
+			http.Error(w, "Payment pending confirmation", http.StatusPaymentRequired)
+			return
+		}
 		if err != nil {
-			http.Error(w, "Payment verification failed: "+err.Error(), http.StatusBadRequest)
+			rejectPayment(w, r, config, &PaymentRejection{
+				Reason:  verificationFailure(payment, quoted),
+				Payment: payment,
+				Err:     err,
+				Status:  http.StatusBadRequest,
+				Message: "Payment verification failed: " + err.Error(),
+			})
+			return
+		}
+
+		if accepted == nil {
+			rejectPayment(w, r, config, &PaymentRejection{
+				Reason:  verificationFailure(payment, quoted),
+				Payment: payment,
+				Status:  http.StatusPaymentRequired,
+				Message: "Payment verification failed",
+			})
 			return
 		}
 
-		if !valid {
-			http.Error(w, "Payment verification failed", http.StatusPaymentRequired)
+		// Redeem the nonce so the payment cannot be replayed
+		redeem := redeemNonce
+		if foreign {
+			redeem = recordNonce
+		}
+		if err := redeem(config, payment.Nonce, quote); err != nil {
+			if isRejection(err) {
+				rejectPayment(w, r, config, nonceRejection(payment, err))
+				return
+			}
+			if errors.Is(err, ErrNonceStoreFull) {
+				http.Error(w, "Too many redeemed payment nonces", http.StatusServiceUnavailable)
+				return
+			}
+			http.Error(w, "Failed to redeem payment nonce", http.StatusInternalServerError)
 			return
 		}
 
 		// Payment verified, call callback if provided
 		if config.OnPaymentVerified != nil {
-			config.OnPaymentVerified(&payment, r)
+			config.OnPaymentVerified(payment, r)
 		}
 
-		// Add payment to context
-		ctx := context.WithValue(r.Context(), paymentContextKey, &PaymentContext{
-			Payment:    payment,
-			Verified:   true,
-			VerifiedAt: time.Now(),
-		})
+		paymentCtx := &PaymentContext{
+			Payment:      *payment,
+			Verified:     true,
+			VerifiedAt:   time.Now(),
+			Requirements: accepted,
+		}
 
-		// Continue to next handler
-		next.ServeHTTP(w, r.WithContext(ctx))
+		// In deposit mode the payment tops up the balance the request is served from
+		if config.Credits != nil {
+			serveDeposit(w, r, config, paymentCtx, accepted, next)
+			return
+		}
+
+		// Add payment to context and continue to next handler, settling the payment as configured
+		ctx := withPaymentContext(r.Context(), paymentCtx)
+		serveAndSettle(w, r.WithContext(ctx), config, paymentCtx, accepted, next)
 	})
 }
 
+// lookupQuote returns the quote a payment was made against, from its signed quote when
+// quotes are signed and from the nonce store otherwise
+func lookupQuote(config *MiddlewareConfig, payment *Payment) (*Quote, error) {
+	if config.QuoteSigner == nil {
+		return config.NonceStore.Lookup(payment.Nonce)
+	}
+
+	quote, err := config.QuoteSigner.Verify(payment.Quote)
+	if err != nil {
+		return nil, err
+	}
+	for _, option := range quote.Options {
+		if option.Nonce != payment.Nonce {
+			return nil, fmt.Errorf("%w: quote was issued for a different nonce", ErrQuoteInvalid)
+		}
+	}
+	return quote, nil
+}
+
+// isForeignPayment reports whether a payment whose quote lookup failed with err was made
+// by a v1 client that chose its own nonce: it names neither a nonce nor a signed quote
+// this server issued.
+func isForeignPayment(r *http.Request, config *MiddlewareConfig, payment *Payment, err error) bool {
+	if r.Header.Get(HeaderPayment) == "" || payment.Authorization == nil || payment.Quote != "" {
+		return false
+	}
+	if config.QuoteSigner != nil {
+		return errors.Is(err, ErrQuoteInvalid)
+	}
+	return errors.Is(err, ErrNonceUnknown)
+}
+
+// quoteRequest prices a request for a payment made without a quote from this server
+func quoteRequest(r *http.Request, config *MiddlewareConfig) (*Quote, error) {
+	options, err := priceRequest(r, config)
+	if err != nil {
+		return nil, err
+	}
+	return &Quote{
+		Resource: resourceURL(r),
+		Options:  options,
+		Expiry:   time.Now().Add(config.ExpiryDuration),
+	}, nil
+}
+
+// checkAuthorizationLifetime refuses authorizations that stay valid for longer than
+// NonceRetention past their quote's expiry, after which the nonce store forgets the nonce
+func checkAuthorizationLifetime(payment *Payment, quote *Quote) error {
+	if payment.Authorization == nil {
+		return nil
+	}
+	validBefore, err := parse.Uint(payment.Authorization.ValidBefore, "validBefore")
+	if err != nil {
+		return err
+	}
+	limit := quote.Expiry.Add(NonceRetention)
+	if validBefore.Cmp(big.NewInt(limit.Unix())) > 0 {
+		return fmt.Errorf("authorization is valid past %s, too long after its quote expires", limit.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// redeemNonce marks a payment's nonce as used. With signed quotes, nonces are only
+// recorded on redemption.
+func redeemNonce(config *MiddlewareConfig, nonce string, quote *Quote) error {
+	if config.QuoteSigner == nil {
+		return config.NonceStore.Consume(nonce)
+	}
+	return recordNonce(config, nonce, quote)
+}
+
+// recordNonce records a nonce the store did not issue as redeemed, failing with
+// ErrNonceConsumed if it was redeemed before. Stores that implement NonceRecorder hold
+// it apart from the nonces they issued.
+func recordNonce(config *MiddlewareConfig, nonce string, quote *Quote) error {
+	if recorder, ok := config.NonceStore.(NonceRecorder); ok {
+		return recorder.Record(nonce, quote)
+	}
+	if _, err := config.NonceStore.Lookup(nonce); !errors.Is(err, ErrNonceUnknown) {
+		if err == nil {
+			return ErrNonceConsumed
+		}
+		return err
+	}
+	if err := config.NonceStore.Issue(nonce, quote); err != nil {
+		return err
+	}
+	return config.NonceStore.Consume(nonce)
+}
+
+// isRejection reports whether err rejects the payment's nonce or quote rather than
+// signalling a server failure
+func isRejection(err error) bool {
+	return errors.Is(err, ErrNonceUnknown) || errors.Is(err, ErrNonceConsumed) ||
+		errors.Is(err, ErrNonceExpired) || errors.Is(err, ErrQuoteInvalid)
+}
+
+// options returns the static payment options, Requirements first
+func (config *MiddlewareConfig) options() []*PaymentRequirements {
+	var options []*PaymentRequirements
+	if config.Requirements != nil {
+		options = append(options, config.Requirements)
+	}
+	for _, option := range config.Accepts {
+		if option != nil {
+			options = append(options, option)
+		}
+	}
+	return options
+}
+
+// facilitatorURL returns the first facilitator URL advertised by the options
+func facilitatorURL(options []*PaymentRequirements) string {
+	for _, option := range options {
+		if option.Facilitator != "" {
+			return option.Facilitator
+		}
+	}
+	return ""
+}
+
+// verifyPayment verifies a payment against the options matching its scheme, chain and
+// token, returning the payment as verified and the option it paid. A nil option means no
+// option accepted the payment. v1 payments do not name their token, so every option on
+// their chain is tried.
+func verifyPayment(ctx context.Context, verifier ContextPaymentVerifier, payment *Payment, options []*PaymentRequirements) (*Payment, *PaymentRequirements, error) {
+	var firstErr error
+	for _, option := range options {
+		if !optionMatches(payment, option) {
+			continue
+		}
+
+		candidate := *payment
+		if candidate.Token == "" {
+			candidate.Token = option.Token
+		}
+
+		valid, err := verifier.VerifyContext(ctx, &candidate, option)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if valid {
+			return &candidate, option, nil
+		}
+	}
+	return payment, nil, firstErr
+}
+
+// optionMatches reports whether a payment has the scheme, chain and token of an option.
+// v1 payments do not name their token and match every option on their chain.
+func optionMatches(payment *Payment, option *PaymentRequirements) bool {
+	if payment.Scheme != option.Scheme || ChainForNetwork(payment.Chain) != ChainForNetwork(option.Chain) {
+		return false
+	}
+	return payment.Token == "" || strings.EqualFold(payment.Token, option.Token)
+}
+
+// readPayment extracts the payment from a request, accepting both the v1 X-PAYMENT
+// header and the legacy X-Payment-Response header. It returns nil if no payment was sent.
+func readPayment(r *http.Request) (*Payment, error) {
+	// v1 clients send a base64 payload in X-PAYMENT
+	if header := r.Header.Get(HeaderPayment); header != "" {
+		var payload PaymentPayloadV1
+		if err := DecodeHeaderV1(header, &payload); err != nil {
+			return nil, err
+		}
+		if payload.X402Version != X402Version {
+			return nil, fmt.Errorf("unsupported x402Version %d", payload.X402Version)
+		}
+		return PaymentFromV1(&payload, nil), nil
+	}
+
+	// Legacy clients send a JSON payment in X-Payment-Response
+	if header := r.Header.Get(HeaderPaymentResponse); header != "" {
+		var payment Payment
+		if err := json.Unmarshal([]byte(header), &payment); err != nil {
+			return nil, err
+		}
+		return &payment, nil
+	}
+
+	return nil, nil
+}
+
+// priceRequest returns the payment options for a request
+func priceRequest(r *http.Request, config *MiddlewareConfig) ([]*PaymentRequirements, error) {
+	if config.RequirementsFunc == nil {
+		return config.options(), nil
+	}
+
+	options, err := config.RequirementsFunc(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var priced []*PaymentRequirements
+	for _, option := range options {
+		if option == nil {
+			continue
+		}
+		if err := option.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid payment requirements: %w", err)
+		}
+		if option.Pass != nil && len(config.PassKey) == 0 {
+			return nil, fmt.Errorf("payment requirements sell a pass but no pass key is configured")
+		}
+		priced = append(priced, option)
+	}
+	if len(priced) == 0 {
+		return nil, fmt.Errorf("no payment options for %s", r.URL.Path)
+	}
+	return priced, nil
+}
+
 // sendPaymentRequired sends a 402 Payment Required response with payment requirements
-func sendPaymentRequired(w http.ResponseWriter, config *MiddlewareConfig) {
-	if config.OnPaymentRequired != nil {
-		config.OnPaymentRequired(w, nil)
+func sendPaymentRequired(w http.ResponseWriter, r *http.Request, config *MiddlewareConfig) {
+	options, err := priceRequest(r, config)
+	if err != nil {
+		http.Error(w, "Failed to price request", http.StatusInternalServerError)
 		return
 	}
 
-	// Set nonce and expiry if not already set
-	requirements := *config.Requirements // Copy
-	if requirements.Nonce == "" {
-		requirements.Nonce = config.NonceGenerator()
+	// All options share a fresh nonce and the expiry
+	nonce := config.NonceGenerator()
+	expiry := time.Now().Add(config.ExpiryDuration).Unix()
+
+	issued := make([]*PaymentRequirements, len(options))
+	for i, option := range options {
+		requirements := *option // Copy
+		requirements.Nonce = nonce
+		if requirements.Expiry == 0 {
+			requirements.Expiry = expiry
+		}
+		if requirements.Resource == "" {
+			requirements.Resource = resourceURL(r)
+		}
+		issued[i] = &requirements
 	}
-	if requirements.Expiry == 0 {
-		requirements.Expiry = time.Now().Add(config.ExpiryDuration).Unix()
+
+	// Record the quote so the payment made against it is verified at these prices, once
+	quote := &Quote{
+		Resource: resourceURL(r),
+		Options:  issued,
+		Expiry:   time.Unix(latestExpiry(issued), 0),
+	}
+	if config.QuoteSigner != nil {
+		// Signed quotes travel with the requirements instead
+		token, err := config.QuoteSigner.Sign(quote)
+		if err != nil {
+			http.Error(w, "Failed to sign payment quote", http.StatusInternalServerError)
+			return
+		}
+		for i, requirements := range issued {
+			issued[i] = withQuoteToken(requirements, token)
+		}
+	} else if err := config.NonceStore.Issue(nonce, quote); err != nil {
+		if errors.Is(err, ErrNonceStoreFull) {
+			http.Error(w, "Too many outstanding payment quotes", http.StatusServiceUnavailable)
+			return
+		}
+		http.Error(w, "Failed to issue payment nonce", http.StatusInternalServerError)
+		return
 	}
 
-	// Convert requirements to JSON
-	reqJSON, err := requirements.ToJSON()
-	if err != nil {
-		http.Error(w, "Failed to generate payment requirements", http.StatusInternalServerError)
+	if config.OnPaymentRequired != nil {
+		config.OnPaymentRequired(w, r, issued)
 		return
 	}
 
-	// Set headers
-	w.Header().Set(HeaderPayment, reqJSON)
-	w.Header().Set(HeaderWWWAuthenticate, "X-Payment")
-	w.Header().Set("Content-Type", "application/json")
+	var body interface{}
+	if config.WireFormat == WireFormatV1 {
+		accepts := make([]PaymentRequirementsV1, len(issued))
+		for i, requirements := range issued {
+			accepts[i] = requirements.ToV1()
+		}
+
+		// v1 carries the requirements in the JSON body only
+		body = &PaymentRequiredResponseV1{
+			X402Version: X402Version,
+			Error:       "X-PAYMENT header is required",
+			Accepts:     accepts,
+		}
+	} else {
+		// Convert requirements to JSON; the header carries the primary option only
+		reqJSON, err := issued[0].ToJSON()
+		if err != nil {
+			http.Error(w, "Failed to generate payment requirements", http.StatusInternalServerError)
+			return
+		}
+
+		// Set headers
+		w.Header().Set(HeaderPayment, reqJSON)
+		w.Header().Set(HeaderWWWAuthenticate, "X-Payment")
+
+		body = map[string]interface{}{
+			"error":   "Payment Required",
+			"payment": issued[0],
+			"accepts": issued,
+		}
+	}
+
+	// Browsers get a paywall page instead of the JSON body
+	if prefersHTML(r) {
+		sendPaywallPage(w, config, issued, body)
+		return
+	}
 
 	// Send 402 response
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusPaymentRequired)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"error":   "Payment Required",
-		"payment": requirements,
-	})
+	json.NewEncoder(w).Encode(body)
+}
+
+// latestExpiry returns the latest expiry among issued requirements
+func latestExpiry(issued []*PaymentRequirements) int64 {
+	var latest int64
+	for _, requirements := range issued {
+		if requirements.Expiry > latest {
+			latest = requirements.Expiry
+		}
+	}
+	return latest
+}
+
+// resourceURL reconstructs the absolute URL of the requested resource
+func resourceURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + r.URL.RequestURI()
+}
+
+// withPaymentContext returns a copy of ctx carrying payment information
+func withPaymentContext(ctx context.Context, pc *PaymentContext) context.Context {
+	return context.WithValue(ctx, paymentContextKey, pc)
 }
 
 // GetPayment retrieves payment information from request context
@@ -159,29 +677,33 @@ func GetPayment(r *http.Request) (*PaymentContext, bool) {
 	return payment, ok
 }
 
-// generateNonce generates a random nonce
+// generateNonce generates a random 32-byte hex nonce, usable directly as an EIP-3009 nonce
 func generateNonce() string {
-	b := make([]byte, 16)
+	b := make([]byte, 32)
 	rand.Read(b)
-	return base64.URLEncoding.EncodeToString(b)
+	return "0x" + hex.EncodeToString(b)
 }
 
 // DefaultVerifier is a basic payment verifier (you should implement proper blockchain verification)
-type DefaultVerifier struct{}
+type DefaultVerifier struct {
+	// Overpayment decides whether amounts above the required amount are accepted
+	// (optional, defaults to AllowOverpayment)
+	Overpayment OverpaymentPolicy
+}
 
 // Verify implements basic validation (should be enhanced with actual blockchain verification)
 func (v *DefaultVerifier) Verify(payment *Payment, requirements *PaymentRequirements) (bool, error) {
 	// Basic validation
-	if payment.Chain != requirements.Chain {
+	if ChainForNetwork(payment.Chain) != ChainForNetwork(requirements.Chain) {
 		return false, nil
 	}
 	if payment.Token != requirements.Token {
 		return false, nil
 	}
-	if payment.Amount != requirements.Amount {
-		return false, nil
+	if covered, err := checkAmount(v.Overpayment, payment.Amount, requirements.Amount); err != nil || !covered {
+		return false, err
 	}
-	if payment.Recipient != requirements.Recipient {
+	if payment.Recipient != authorizationRecipient(requirements) {
 		return false, nil
 	}
 
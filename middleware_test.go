@@ -0,0 +1,164 @@
+package x402go
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// testRequirements returns exact requirements in Base USDC, naming its EIP-712 domain
+func testRequirements() *PaymentRequirements {
+	return &PaymentRequirements{
+		Scheme:    SchemeExact,
+		Amount:    "1000",
+		Token:     "0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913",
+		Chain:     "8453",
+		Recipient: "0x1111111111111111111111111111111111111111",
+		Extra:     map[string]interface{}{"name": "USD Coin", "version": "2"},
+	}
+}
+
+func TestForeignNonceV1Payment(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		signer bool
+	}{
+		{"nonce store", false},
+		{"signed quotes", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &MiddlewareConfig{
+				Requirements: testRequirements(),
+				Verifier:     &EIP3009Verifier{},
+			}
+			if tt.signer {
+				config.QuoteSigner, err = NewQuoteSigner([]byte("0123456789abcdef"))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			server := httptest.NewServer(RequirePaymentWithConfig(config, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("ok"))
+			})))
+			defer server.Close()
+
+			// Read the advertised option the way any v1 client would
+			resp, err := http.Get(server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var body PaymentRequiredResponseV1
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			// Pay with a nonce of the client's own choosing and no quote
+			requirements := RequirementsFromV1(&body.Accepts[0])
+			requirements.Nonce = ""
+			payment, err := NewSignerPaymentHandler(NewPrivateKeySigner(key), nil)(context.Background(), requirements)
+			if err != nil {
+				t.Fatal(err)
+			}
+			payment.Quote = ""
+			header, err := EncodeHeaderV1(payment.ToV1())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i, want := range []int{http.StatusOK, http.StatusPaymentRequired} {
+				req, _ := http.NewRequest("GET", server.URL, nil)
+				req.Header.Set(HeaderPayment, header)
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+				if resp.StatusCode != want {
+					t.Fatalf("attempt %d: status %d (%s), want %d", i+1, resp.StatusCode, resp.Header.Get(HeaderPaymentError), want)
+				}
+			}
+		})
+	}
+}
+
+func TestV1PaymentReplayAfterExpiry(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		validBefore time.Duration
+		want        []int
+	}{
+		{"replayed after the quote expires", 5 * time.Minute, []int{http.StatusOK, http.StatusPaymentRequired}},
+		{"authorization outlives the nonce", 24 * time.Hour, []int{http.StatusPaymentRequired}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryNonceStore()
+			config := &MiddlewareConfig{
+				Requirements:   testRequirements(),
+				Verifier:       &EIP3009Verifier{},
+				NonceStore:     store,
+				ExpiryDuration: 100 * time.Millisecond,
+			}
+			server := httptest.NewServer(RequirePaymentWithConfig(config, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("ok"))
+			})))
+			defer server.Close()
+
+			// Pay with a nonce of the client's own choosing, authorized past the quote's expiry
+			requirements := testRequirements()
+			requirements.Expiry = time.Now().Add(tt.validBefore).Unix()
+			payment, err := NewSignerPaymentHandler(NewPrivateKeySigner(key), nil)(context.Background(), requirements)
+			if err != nil {
+				t.Fatal(err)
+			}
+			header, err := EncodeHeaderV1(payment.ToV1())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pay := func() int {
+				req, _ := http.NewRequest("GET", server.URL, nil)
+				req.Header.Set(HeaderPayment, header)
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+				return resp.StatusCode
+			}
+			for i, want := range tt.want {
+				if status := pay(); status != want {
+					t.Fatalf("attempt %d: status %d, want %d", i+1, status, want)
+				}
+			}
+
+			// The redeemed nonce outlives its quote, so sweeping does not make it payable again
+			time.Sleep(200 * time.Millisecond)
+			if err := store.Expire(); err != nil {
+				t.Fatal(err)
+			}
+			if status := pay(); status != http.StatusPaymentRequired {
+				t.Fatalf("after expiry: status %d, want %d", status, http.StatusPaymentRequired)
+			}
+		})
+	}
+}
@@ -0,0 +1,484 @@
+package x402go
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNonceUnknown is returned when a payment carries a nonce that was never issued
+	ErrNonceUnknown = errors.New("nonce was not issued")
+
+	// ErrNonceConsumed is returned when a nonce has already been redeemed
+	ErrNonceConsumed = errors.New("nonce already consumed")
+
+	// ErrNonceExpired is returned when the payment requirement for a nonce has expired
+	ErrNonceExpired = errors.New("nonce expired")
+
+	// ErrNonceStoreFull is returned when a nonce store holds as many unexpired nonces of
+	// a kind as it may
+	ErrNonceStoreFull = errors.New("nonce store full")
+)
+
+// DefaultMaxNonces is how many unexpired nonces a nonce store holds unless configured
+// otherwise, counting issued and recorded nonces separately
+const DefaultMaxNonces = 100000
+
+// NonceRetention is how long a nonce is kept after its quote expires. Authorizations that
+// stay valid for longer past the quote's expiry are refused, so a redeemed nonce is
+// remembered for as long as a payment under it could be replayed.
+const NonceRetention = 10 * time.Minute
+
+// NonceStore tracks nonces handed out in 402 responses so that each can be redeemed only once
+type NonceStore interface {
+	// Issue records a newly generated nonce together with the quote it was issued with
+	Issue(nonce string, quote *Quote) error
+
+	// Lookup returns the quote a nonce was issued with. It fails with ErrNonceUnknown,
+	// ErrNonceConsumed or ErrNonceExpired if the nonce cannot be used
+	Lookup(nonce string) (*Quote, error)
+
+	// Consume redeems a nonce. It fails with ErrNonceUnknown, ErrNonceConsumed
+	// or ErrNonceExpired if the nonce cannot be used
+	Consume(nonce string) error
+
+	// Expire drops nonces whose expiry passed more than NonceRetention ago. Until then
+	// they are reported as expired or consumed, never as unknown.
+	Expire() error
+}
+
+// NonceRecorder is implemented by nonce stores that can record a nonce they did not
+// issue, such as one chosen by a v1 client or carried in a signed quote, as redeemed.
+// Recorded nonces are held apart from issued ones, so payments made under nonces of
+// the clients' choosing cannot crowd out the nonces handed out in 402 responses.
+type NonceRecorder interface {
+	// Record stores a nonce as redeemed together with the quote it was paid against.
+	// It fails with ErrNonceConsumed or ErrNonceExpired if the store already holds the
+	// nonce.
+	Record(nonce string, quote *Quote) error
+}
+
+// Quote is what a 402 response offered for a single nonce: the resource it was issued
+// for and the payment options with the prices quoted at that time
+type Quote struct {
+	// Resource is the URL of the request the quote was issued for
+	Resource string `json:"resource,omitempty"`
+
+	// Options are the payment options as advertised, nonce and expiry included
+	Options []*PaymentRequirements `json:"options,omitempty"`
+
+	// Expiry is when the quote stops being payable
+	Expiry time.Time `json:"expiry"`
+}
+
+// nonceEntry is the state kept for a single issued nonce
+type nonceEntry struct {
+	Quote
+	Consumed bool `json:"consumed"`
+
+	// Recorded is set for nonces the store did not issue
+	Recorded bool `json:"recorded,omitempty"`
+}
+
+// nonceSet is the shared bookkeeping behind the NonceStore implementations
+type nonceSet struct {
+	entries map[string]*nonceEntry
+
+	// recorded is the number of entries with Recorded set
+	recorded int
+}
+
+func newNonceSet() *nonceSet {
+	return &nonceSet{entries: make(map[string]*nonceEntry)}
+}
+
+// add stores an entry, replacing any entry held for the nonce
+func (s *nonceSet) add(nonce string, entry *nonceEntry) {
+	s.remove(nonce)
+	s.entries[nonce] = entry
+	if entry.Recorded {
+		s.recorded++
+	}
+}
+
+// remove drops the entry of a nonce if there is one
+func (s *nonceSet) remove(nonce string) {
+	if entry, ok := s.entries[nonce]; ok {
+		delete(s.entries, nonce)
+		if entry.Recorded {
+			s.recorded--
+		}
+	}
+}
+
+// issued is the number of entries for nonces the store issued
+func (s *nonceSet) issued() int {
+	return len(s.entries) - s.recorded
+}
+
+// reserve makes room for an entry counted by count, sweeping expired entries first if
+// count has reached max
+func (s *nonceSet) reserve(nonce string, count func() int, max int, now time.Time) error {
+	if max > 0 && count() >= max {
+		s.expire(now)
+		if count() >= max {
+			return ErrNonceStoreFull
+		}
+	}
+	if nonce == "" {
+		return fmt.Errorf("nonce cannot be empty")
+	}
+	return nil
+}
+
+// issue records a nonce, sweeping expired ones first if the set holds max issued nonces
+func (s *nonceSet) issue(nonce string, quote *Quote, max int, now time.Time) error {
+	if err := s.reserve(nonce, s.issued, max, now); err != nil {
+		return err
+	}
+	if _, exists := s.entries[nonce]; exists {
+		return fmt.Errorf("nonce %q already issued", nonce)
+	}
+	s.add(nonce, &nonceEntry{Quote: *quote})
+	return nil
+}
+
+// record stores a nonce the set did not issue as consumed, sweeping expired ones first
+// if the set holds max recorded nonces
+func (s *nonceSet) record(nonce string, quote *Quote, max int, now time.Time) error {
+	if _, exists := s.entries[nonce]; exists {
+		if _, err := s.usable(nonce, now); err != nil {
+			return err
+		}
+		return ErrNonceConsumed
+	}
+	if err := s.reserve(nonce, func() int { return s.recorded }, max, now); err != nil {
+		return err
+	}
+	s.add(nonce, &nonceEntry{Quote: *quote, Consumed: true, Recorded: true})
+	return nil
+}
+
+// usable returns the entry of a nonce that can still be redeemed
+func (s *nonceSet) usable(nonce string, now time.Time) (*nonceEntry, error) {
+	entry, ok := s.entries[nonce]
+	if !ok {
+		return nil, ErrNonceUnknown
+	}
+	if entry.Consumed {
+		return nil, ErrNonceConsumed
+	}
+	if now.After(entry.Expiry) {
+		return nil, ErrNonceExpired
+	}
+	return entry, nil
+}
+
+func (s *nonceSet) lookup(nonce string, now time.Time) (*Quote, error) {
+	entry, err := s.usable(nonce, now)
+	if err != nil {
+		return nil, err
+	}
+	quote := entry.Quote
+	return &quote, nil
+}
+
+func (s *nonceSet) consume(nonce string, now time.Time) error {
+	entry, err := s.usable(nonce, now)
+	if err != nil {
+		return err
+	}
+	entry.Consumed = true
+	return nil
+}
+
+// expire removes entries whose expiry passed more than NonceRetention ago and reports
+// whether anything was removed. Entries are kept past their expiry so a nonce is not
+// mistaken for one the server never issued while payments under it can still be valid.
+func (s *nonceSet) expire(now time.Time) bool {
+	removed := false
+	for nonce, entry := range s.entries {
+		if now.After(entry.Expiry.Add(NonceRetention)) {
+			s.remove(nonce)
+			removed = true
+		}
+	}
+	return removed
+}
+
+// MemoryNonceStore is an in-memory NonceStore. Expired entries are swept as new nonces
+// are issued, at most every SweepInterval and whenever the store is full.
+type MemoryNonceStore struct {
+	mu        sync.Mutex
+	nonces    *nonceSet
+	lastSweep time.Time
+
+	// SweepInterval is the minimum time between sweeps of expired nonces (default: 1 minute)
+	SweepInterval time.Duration
+
+	// MaxNonces caps the issued nonces held, zero for no cap; Issue fails with
+	// ErrNonceStoreFull beyond it (default: DefaultMaxNonces)
+	MaxNonces int
+
+	// MaxRecordedNonces caps the recorded nonces held, zero for no cap; Record fails with
+	// ErrNonceStoreFull beyond it (default: DefaultMaxNonces)
+	MaxRecordedNonces int
+}
+
+// NewMemoryNonceStore creates an empty in-memory nonce store
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{
+		nonces:            newNonceSet(),
+		lastSweep:         time.Now(),
+		SweepInterval:     time.Minute,
+		MaxNonces:         DefaultMaxNonces,
+		MaxRecordedNonces: DefaultMaxNonces,
+	}
+}
+
+// Issue implements NonceStore
+func (s *MemoryNonceStore) Issue(nonce string, quote *Quote) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.nonces.issue(nonce, quote, s.MaxNonces, s.sweep())
+}
+
+// Record implements NonceRecorder
+func (s *MemoryNonceStore) Record(nonce string, quote *Quote) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.nonces.record(nonce, quote, s.MaxRecordedNonces, s.sweep())
+}
+
+// sweep drops expired nonces if SweepInterval has passed since the last sweep and
+// returns the current time
+func (s *MemoryNonceStore) sweep() time.Time {
+	now := time.Now()
+	if now.Sub(s.lastSweep) >= s.SweepInterval {
+		s.nonces.expire(now)
+		s.lastSweep = now
+	}
+	return now
+}
+
+// Lookup implements NonceStore
+func (s *MemoryNonceStore) Lookup(nonce string) (*Quote, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.nonces.lookup(nonce, time.Now())
+}
+
+// Consume implements NonceStore
+func (s *MemoryNonceStore) Consume(nonce string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.nonces.consume(nonce, time.Now())
+}
+
+// Expire implements NonceStore
+func (s *MemoryNonceStore) Expire() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nonces.expire(time.Now())
+	s.lastSweep = time.Now()
+	return nil
+}
+
+// FileNonceStore is a NonceStore persisted as an append-only log, so issued and
+// consumed nonces survive process restarts. Issuing a nonce appends a record; consuming
+// one appends a record and syncs it to disk before the payment is accepted. The log is
+// rewritten without expired nonces each time it doubles in size.
+type FileNonceStore struct {
+	mu     sync.Mutex
+	log    appendLog
+	nonces *nonceSet
+
+	// MaxNonces caps the issued nonces held, zero for no cap; Issue fails with
+	// ErrNonceStoreFull beyond it (default: DefaultMaxNonces)
+	MaxNonces int
+
+	// MaxRecordedNonces caps the recorded nonces held, zero for no cap; Record fails with
+	// ErrNonceStoreFull beyond it (default: DefaultMaxNonces)
+	MaxRecordedNonces int
+}
+
+// nonceRecord is a record of the FileNonceStore log: an issued or recorded nonce with
+// its quote, or a consumed nonce
+type nonceRecord struct {
+	Nonce    string `json:"nonce"`
+	Quote    *Quote `json:"quote,omitempty"`
+	Consumed bool   `json:"consumed,omitempty"`
+	Recorded bool   `json:"recorded,omitempty"`
+}
+
+// NewFileNonceStore opens the nonce store at path, creating the file if it does not exist
+func NewFileNonceStore(path string) (*FileNonceStore, error) {
+	s := &FileNonceStore{
+		log:               appendLog{path: path, name: "nonce store"},
+		nonces:            newNonceSet(),
+		MaxNonces:         DefaultMaxNonces,
+		MaxRecordedNonces: DefaultMaxNonces,
+	}
+	if err := s.log.replay(s.apply); err != nil {
+		return nil, err
+	}
+
+	// Start from a compacted log, which also drops a record torn by a crash
+	s.nonces.expire(time.Now())
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// apply applies a record of the log
+func (s *FileNonceStore) apply(data []byte) error {
+	var record nonceRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return err
+	}
+
+	switch {
+	case record.Quote != nil:
+		s.nonces.add(record.Nonce, &nonceEntry{Quote: *record.Quote, Consumed: record.Consumed, Recorded: record.Recorded})
+	case record.Consumed:
+		if entry, ok := s.nonces.entries[record.Nonce]; ok {
+			entry.Consumed = true
+		}
+	}
+	return nil
+}
+
+// Issue implements NonceStore
+func (s *FileNonceStore) Issue(nonce string, quote *Quote) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.nonces.issue(nonce, quote, s.MaxNonces, time.Now()); err != nil {
+		return err
+	}
+
+	// An issued nonce lost in a crash only costs the client a new quote, so it is not synced
+	if err := s.log.append(&nonceRecord{Nonce: nonce, Quote: quote}, false); err != nil {
+		s.nonces.remove(nonce)
+		s.compact()
+		return err
+	}
+	return s.compactIfSparse()
+}
+
+// Record implements NonceRecorder. The record is synced to disk before the payment is
+// accepted.
+func (s *FileNonceStore) Record(nonce string, quote *Quote) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.nonces.record(nonce, quote, s.MaxRecordedNonces, time.Now()); err != nil {
+		return err
+	}
+	if err := s.log.append(&nonceRecord{Nonce: nonce, Quote: quote, Consumed: true, Recorded: true}, true); err != nil {
+		s.nonces.remove(nonce)
+		s.compact()
+		return err
+	}
+	return s.compactIfSparse()
+}
+
+// Lookup implements NonceStore
+func (s *FileNonceStore) Lookup(nonce string) (*Quote, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.nonces.lookup(nonce, time.Now())
+}
+
+// Consume implements NonceStore
+func (s *FileNonceStore) Consume(nonce string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.nonces.consume(nonce, time.Now()); err != nil {
+		return err
+	}
+	if err := s.log.append(&nonceRecord{Nonce: nonce, Consumed: true}, true); err != nil {
+		s.nonces.entries[nonce].Consumed = false
+		s.compact()
+		return err
+	}
+	return nil
+}
+
+// Expire implements NonceStore
+func (s *FileNonceStore) Expire() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.nonces.expire(time.Now()) {
+		return nil
+	}
+	return s.compact()
+}
+
+// Close closes the log file
+func (s *FileNonceStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.log.close()
+}
+
+// compactIfSparse compacts the log once it has doubled in size, dropping expired nonces
+func (s *FileNonceStore) compactIfSparse() error {
+	if !s.log.sparse() {
+		return nil
+	}
+	s.nonces.expire(time.Now())
+	return s.compact()
+}
+
+// compact rewrites the log with one record per nonce held. After a failed append it
+// repairs the log; the append's error is what gets reported.
+func (s *FileNonceStore) compact() error {
+	records := make([]interface{}, 0, len(s.nonces.entries))
+	for nonce, entry := range s.nonces.entries {
+		quote := entry.Quote
+		records = append(records, &nonceRecord{Nonce: nonce, Quote: &quote, Consumed: entry.Consumed, Recorded: entry.Recorded})
+	}
+	return s.log.compact(records)
+}
+
+// writeFileAtomic writes data to a temporary file next to path, syncs it and renames it
+// into place, so a crash leaves either the old file or the new one
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	// Sync the directory so the rename itself survives a crash
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
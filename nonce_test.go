@@ -0,0 +1,257 @@
+package x402go
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNonceStores(t *testing.T) {
+	stores := []struct {
+		name string
+		open func(t *testing.T) NonceStore
+	}{
+		{"memory", func(t *testing.T) NonceStore { return NewMemoryNonceStore() }},
+		{"file", func(t *testing.T) NonceStore {
+			store, err := NewFileNonceStore(filepath.Join(t.TempDir(), "nonces.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { store.Close() })
+			return store
+		}},
+	}
+
+	tests := []struct {
+		name    string
+		expiry  time.Duration
+		consume int
+		nonce   string
+		wantErr error
+	}{
+		{"unused nonce", time.Minute, 0, "n1", nil},
+		{"redeemed once", time.Minute, 1, "n1", ErrNonceConsumed},
+		{"redeemed twice", time.Minute, 2, "n1", ErrNonceConsumed},
+		{"expired nonce", -time.Second, 0, "n1", ErrNonceExpired},
+		{"unknown nonce", time.Minute, 0, "n2", ErrNonceUnknown},
+	}
+
+	for _, st := range stores {
+		for _, tt := range tests {
+			t.Run(st.name+"/"+tt.name, func(t *testing.T) {
+				store := st.open(t)
+				if err := store.Issue("n1", &Quote{Resource: "/r", Expiry: time.Now().Add(tt.expiry)}); err != nil {
+					t.Fatal(err)
+				}
+
+				for i := 0; i < tt.consume; i++ {
+					err := store.Consume("n1")
+					if i == 0 && err != nil {
+						t.Fatalf("first Consume() = %v", err)
+					}
+					if i > 0 && !errors.Is(err, ErrNonceConsumed) {
+						t.Fatalf("Consume() again = %v, want %v", err, ErrNonceConsumed)
+					}
+				}
+
+				if _, err := store.Lookup(tt.nonce); !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Lookup() = %v, want %v", err, tt.wantErr)
+				}
+				if tt.wantErr == nil {
+					if err := store.Consume(tt.nonce); err != nil {
+						t.Fatalf("Consume() = %v", err)
+					}
+				} else if err := store.Consume(tt.nonce); !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Consume() = %v, want %v", err, tt.wantErr)
+				}
+			})
+		}
+	}
+}
+
+func TestNonceStoreExpire(t *testing.T) {
+	store := NewMemoryNonceStore()
+	expiries := map[string]time.Duration{
+		"live":     time.Minute,
+		"expired":  -time.Second,
+		"consumed": time.Millisecond,
+		"stale":    -NonceRetention - time.Second,
+	}
+	for nonce, expiry := range expiries {
+		if err := store.Issue(nonce, &Quote{Expiry: time.Now().Add(expiry)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Consume("consumed"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	if err := store.Expire(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nonces are only forgotten once NonceRetention has passed since their expiry
+	tests := []struct {
+		nonce   string
+		wantErr error
+	}{
+		{"live", nil},
+		{"expired", ErrNonceExpired},
+		{"consumed", ErrNonceConsumed},
+		{"stale", ErrNonceUnknown},
+	}
+	for _, tt := range tests {
+		if _, err := store.Lookup(tt.nonce); !errors.Is(err, tt.wantErr) {
+			t.Errorf("Lookup(%s) = %v, want %v", tt.nonce, err, tt.wantErr)
+		}
+	}
+}
+
+func TestFileNonceStoreReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nonces.json")
+	store, err := NewFileNonceStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Issue("n1", &Quote{Expiry: time.Now().Add(time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Consume("n1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Issue("n2", &Quote{Expiry: time.Now().Add(time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	// A record torn by a crash while it was appended is dropped
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte(`{"nonce":"n2","consu`))
+	f.Close()
+
+	reopened, err := NewFileNonceStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if err := reopened.Consume("n1"); !errors.Is(err, ErrNonceConsumed) {
+		t.Fatalf("Consume(n1) after reopening = %v, want %v", err, ErrNonceConsumed)
+	}
+	if err := reopened.Consume("n2"); err != nil {
+		t.Fatalf("Consume(n2) after reopening = %v", err)
+	}
+}
+
+func TestNonceStoreMaxNonces(t *testing.T) {
+	memory := NewMemoryNonceStore()
+	file, err := NewFileNonceStore(filepath.Join(t.TempDir(), "nonces.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	memory.MaxNonces, file.MaxNonces = 2, 2
+
+	for _, store := range []NonceStore{memory, file} {
+		if err := store.Issue("stale", &Quote{Expiry: time.Now().Add(-NonceRetention - time.Second)}); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Issue("n1", &Quote{Expiry: time.Now().Add(time.Minute)}); err != nil {
+			t.Fatal(err)
+		}
+
+		// A full store sweeps expired nonces before refusing new ones
+		if err := store.Issue("n2", &Quote{Expiry: time.Now().Add(time.Minute)}); err != nil {
+			t.Fatalf("%T: Issue() after sweep = %v", store, err)
+		}
+		if err := store.Issue("n3", &Quote{Expiry: time.Now().Add(time.Minute)}); !errors.Is(err, ErrNonceStoreFull) {
+			t.Fatalf("%T: Issue() when full = %v, want %v", store, err, ErrNonceStoreFull)
+		}
+	}
+}
+
+func TestNonceStoreRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nonces.json")
+	memory := NewMemoryNonceStore()
+	file, err := NewFileNonceStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	memory.MaxNonces, memory.MaxRecordedNonces = 1, 2
+	file.MaxNonces, file.MaxRecordedNonces = 1, 2
+
+	quote := &Quote{Expiry: time.Now().Add(time.Minute)}
+	for _, store := range []interface {
+		NonceStore
+		NonceRecorder
+	}{memory, file} {
+		for _, nonce := range []string{"r1", "r2"} {
+			if err := store.Record(nonce, quote); err != nil {
+				t.Fatalf("%T: Record(%s) = %v", store, nonce, err)
+			}
+		}
+		if err := store.Record("r1", quote); !errors.Is(err, ErrNonceConsumed) {
+			t.Fatalf("%T: Record() again = %v, want %v", store, err, ErrNonceConsumed)
+		}
+		if _, err := store.Lookup("r1"); !errors.Is(err, ErrNonceConsumed) {
+			t.Fatalf("%T: Lookup() of a recorded nonce = %v, want %v", store, err, ErrNonceConsumed)
+		}
+		if err := store.Record("r3", quote); !errors.Is(err, ErrNonceStoreFull) {
+			t.Fatalf("%T: Record() when full = %v, want %v", store, err, ErrNonceStoreFull)
+		}
+
+		// Recorded nonces do not count against the cap on issued ones
+		if err := store.Issue("n1", quote); err != nil {
+			t.Fatalf("%T: Issue() with recorded nonces held = %v", store, err)
+		}
+		if err := store.Record("n1", quote); !errors.Is(err, ErrNonceConsumed) {
+			t.Fatalf("%T: Record() of an issued nonce = %v, want %v", store, err, ErrNonceConsumed)
+		}
+	}
+
+	// Recorded nonces are still told apart after a restart
+	file.Close()
+	reopened, err := NewFileNonceStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	reopened.MaxNonces, reopened.MaxRecordedNonces = 2, 2
+	if err := reopened.Record("r3", quote); !errors.Is(err, ErrNonceStoreFull) {
+		t.Fatalf("reopened Record() when full = %v, want %v", err, ErrNonceStoreFull)
+	}
+	if err := reopened.Issue("n2", quote); err != nil {
+		t.Fatalf("reopened Issue() = %v", err)
+	}
+}
+
+func TestFileNonceStoreCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nonces.json")
+	store, err := NewFileNonceStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	// Expired nonces are dropped from the log as it grows
+	for i := 0; i < 3*minLogCompaction; i++ {
+		if err := store.Issue(fmt.Sprintf("n%d", i), &Quote{Expiry: time.Now().Add(-NonceRetention - time.Millisecond)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records := bytes.Count(data, []byte("\n")); records > minLogCompaction {
+		t.Fatalf("log holds %d records, want at most %d", records, minLogCompaction)
+	}
+}
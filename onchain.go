@@ -0,0 +1,215 @@
+package x402go
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/berhberhberh/x402go/internal/parse"
+)
+
+// erc20TransferTopic is the topic of the ERC-20 Transfer(address,address,uint256) event
+var erc20TransferTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+
+// defaultMaxTransferAge is how long after its block a transfer is accepted unless configured otherwise
+const defaultMaxTransferAge = time.Hour
+
+// ReceiptBackend is the chain access needed by OnChainVerifier: the receipt of the
+// payment transaction, the head block to count its confirmations and the transfer's
+// block for its age.
+type ReceiptBackend interface {
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
+// OnChainVerifier is a PaymentVerifier for payments made by submitting an ERC-20 transfer
+// and passing its TxHash. It decodes the Transfer logs of the transaction receipt and
+// requires the transfer to be buried under enough blocks. Each transaction is bound to the
+// nonce of the first payment it verifies for, so it cannot pay for another quote, until it
+// is older than MaxAge and no longer accepted at all.
+type OnChainVerifier struct {
+	// Backend reads receipts and headers from the chain
+	Backend ReceiptBackend
+
+	// Chain is the chain ID the backend is connected to. Payments on other chains are rejected.
+	Chain string
+
+	// Confirmations is the number of blocks the transfer's block must have on top of it,
+	// counting its own block (default: 1, the transfer is included)
+	Confirmations uint64
+
+	// Overpayment decides whether a transfer above the required amount is accepted
+	// (optional, defaults to AllowOverpayment)
+	Overpayment OverpaymentPolicy
+
+	// MaxAge is how long after its block a transfer is accepted as payment (default: 1 hour).
+	// Transactions are remembered for as long.
+	MaxAge time.Duration
+
+	// Used records the nonce each transaction paid (optional, defaults to an in-memory
+	// store). Use a FileTxStore so transactions cannot pay again after a restart.
+	Used TxStore
+
+	mu sync.Mutex
+}
+
+// NewOnChainVerifier creates a verifier reading transfers on chain through backend
+func NewOnChainVerifier(backend ReceiptBackend, chain string, confirmations uint64) *OnChainVerifier {
+	return &OnChainVerifier{
+		Backend:       backend,
+		Chain:         chain,
+		Confirmations: confirmations,
+		Used:          NewMemoryTxStore(),
+	}
+}
+
+// Verify implements PaymentVerifier
+func (v *OnChainVerifier) Verify(payment *Payment, requirements *PaymentRequirements) (bool, error) {
+	return v.VerifyContext(context.Background(), payment, requirements)
+}
+
+// VerifyContext implements ContextPaymentVerifier. It returns ErrPaymentPending while the
+// transaction is not yet mined or lacks confirmations. Each transaction pays for a single
+// nonce; the middleware's nonce store then keeps the payment from being replayed.
+func (v *OnChainVerifier) VerifyContext(ctx context.Context, payment *Payment, requirements *PaymentRequirements) (bool, error) {
+	if payment.TxHash == "" {
+		return false, fmt.Errorf("payment carries no transaction hash")
+	}
+	if payment.Nonce == "" {
+		return false, fmt.Errorf("payment carries no nonce")
+	}
+
+	chain := ChainForNetwork(requirements.Chain)
+	if ChainForNetwork(payment.Chain) != chain || ChainForNetwork(v.Chain) != chain {
+		return false, nil
+	}
+
+	txHash := common.HexToHash(payment.TxHash)
+
+	token, err := parse.Address(requirements.Token)
+	if err != nil {
+		return false, err
+	}
+	recipient, err := parse.Address(requirements.Recipient)
+	if err != nil {
+		return false, err
+	}
+	sender, err := parse.Address(payment.Sender)
+	if err != nil {
+		return false, err
+	}
+	required, err := ParseAmount(requirements.Amount)
+	if err != nil {
+		return false, err
+	}
+
+	receipt, err := v.Backend.TransactionReceipt(ctx, txHash)
+	if errors.Is(err, ethereum.NotFound) {
+		return false, ErrPaymentPending
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch receipt: %w", err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return false, nil
+	}
+
+	// Require the transfer's block to be buried deep enough
+	head, err := v.Backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch head block: %w", err)
+	}
+	if confirmations(receipt.BlockNumber, head.Number) < v.requiredConfirmations() {
+		return false, ErrPaymentPending
+	}
+
+	// Refuse transfers too old to be remembered by the transaction store
+	block, err := v.Backend.HeaderByNumber(ctx, receipt.BlockNumber)
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch block: %w", err)
+	}
+	expiry := time.Unix(int64(block.Time), 0).Add(v.maxAge())
+	if time.Now().After(expiry) {
+		return false, nil
+	}
+
+	// Sum the token transfers from the sender to the recipient
+	paid, _ := NewAmount(transferredAmount(receipt.Logs, token, sender, recipient))
+	overpayment := v.Overpayment
+	if overpayment == nil {
+		overpayment = AllowOverpayment
+	}
+	if !overpayment(paid, required) {
+		return false, nil
+	}
+
+	// Bind the transaction to the payment's nonce so it cannot pay for another quote
+	claimed, err := v.txStore().Claim(txHash.Hex(), payment.Nonce, expiry)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim transaction: %w", err)
+	}
+	return claimed, nil
+}
+
+// requiredConfirmations returns the configured confirmations, at least 1
+func (v *OnChainVerifier) requiredConfirmations() uint64 {
+	if v.Confirmations == 0 {
+		return 1
+	}
+	return v.Confirmations
+}
+
+// maxAge returns the configured maximum transfer age, 1 hour if unset
+func (v *OnChainVerifier) maxAge() time.Duration {
+	if v.MaxAge == 0 {
+		return defaultMaxTransferAge
+	}
+	return v.MaxAge
+}
+
+// txStore returns the configured transaction store, creating an in-memory one if unset
+func (v *OnChainVerifier) txStore() TxStore {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.Used == nil {
+		v.Used = NewMemoryTxStore()
+	}
+	return v.Used
+}
+
+// confirmations returns how many blocks, including its own, a block at number has under head
+func confirmations(number, head *big.Int) uint64 {
+	if number == nil || head == nil || head.Cmp(number) < 0 {
+		return 0
+	}
+	return new(big.Int).Sub(head, number).Uint64() + 1
+}
+
+// transferredAmount sums the ERC-20 Transfer logs of token from sender to recipient
+func transferredAmount(logs []*types.Log, token, sender, recipient common.Address) *big.Int {
+	total := new(big.Int)
+	for _, log := range logs {
+		if log.Address != token || log.Removed {
+			continue
+		}
+		if len(log.Topics) != 3 || log.Topics[0] != erc20TransferTopic || len(log.Data) != 32 {
+			continue
+		}
+		from := common.BytesToAddress(log.Topics[1].Bytes())
+		to := common.BytesToAddress(log.Topics[2].Bytes())
+		if from != sender || to != recipient {
+			continue
+		}
+		total.Add(total, new(big.Int).SetBytes(log.Data))
+	}
+	return total
+}
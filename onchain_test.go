@@ -0,0 +1,122 @@
+package x402go
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/berhberhberh/x402go/internal/testtoken"
+)
+
+func TestOnChainVerifier(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	payer := crypto.PubkeyToAddress(key.PublicKey)
+	ether := new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+	sim := backends.NewSimulatedBackend(core.GenesisAlloc{payer: {Balance: ether}}, 30_000_000)
+	defer sim.Close()
+
+	// Blocks are dated five minutes back, close enough to now to be accepted; the chain
+	// refuses blocks from the future
+	if err := sim.AdjustTime(time.Duration(time.Now().Add(-5*time.Minute).Unix()) * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	sim.Commit()
+
+	chainID := big.NewInt(1337)
+	opts, err := bind.NewKeyedTransactorWithChainID(key, chainID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := testtoken.Deploy(opts, sim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim.Commit()
+	if _, err := token.Mint(opts, payer, big.NewInt(1000)); err != nil {
+		t.Fatal(err)
+	}
+	sim.Commit()
+
+	requirements := &PaymentRequirements{
+		Scheme:    SchemeExact,
+		Amount:    "400",
+		Token:     token.Address.Hex(),
+		Chain:     chainID.String(),
+		Recipient: "0x1111111111111111111111111111111111111111",
+		Nonce:     "0x0a",
+	}
+
+	// Requirements without an EIP-712 domain are paid with a transfer transaction
+	payment, err := NewSignerPaymentHandler(NewPrivateKeySigner(key), sim)(context.Background(), requirements)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.TxHash == "" {
+		t.Fatal("payment carries no transaction hash")
+	}
+
+	verifier := NewOnChainVerifier(sim, chainID.String(), 2)
+
+	// The transfer is pending until it is mined and confirmed
+	if _, err := verifier.Verify(payment, requirements); !errors.Is(err, ErrPaymentPending) {
+		t.Fatalf("unmined transfer: err = %v, want ErrPaymentPending", err)
+	}
+	sim.Commit()
+	if _, err := verifier.Verify(payment, requirements); !errors.Is(err, ErrPaymentPending) {
+		t.Fatalf("unconfirmed transfer: err = %v, want ErrPaymentPending", err)
+	}
+	sim.Commit()
+
+	tests := []struct {
+		name   string
+		nonce  string
+		amount string
+		want   bool
+	}{
+		{"underpaid", "0x0a", "401", false},
+		{"paid", "0x0a", "400", true},
+		{"retried under the same nonce", "0x0a", "400", true},
+		{"reused for another nonce", "0x0b", "400", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paid := *payment
+			paid.Nonce = tt.nonce
+			required := *requirements
+			required.Amount = tt.amount
+
+			valid, err := verifier.Verify(&paid, &required)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if valid != tt.want {
+				t.Errorf("Verify() = %v, want %v", valid, tt.want)
+			}
+		})
+	}
+
+	// Transfers from another sender do not pay
+	other := *payment
+	other.Sender = common.HexToAddress("0x2222222222222222222222222222222222222222").Hex()
+	other.Nonce = "0x0c"
+	if valid, err := NewOnChainVerifier(sim, chainID.String(), 1).Verify(&other, requirements); err != nil || valid {
+		t.Errorf("transfer from another sender: Verify() = %v, %v, want false", valid, err)
+	}
+
+	// Transfers older than MaxAge are no longer accepted, so they can be forgotten
+	stale := *payment
+	stale.Nonce = "0x0d"
+	old := NewOnChainVerifier(sim, chainID.String(), 1)
+	old.MaxAge = time.Minute
+	if valid, err := old.Verify(&stale, requirements); err != nil || valid {
+		t.Errorf("transfer older than MaxAge: Verify() = %v, %v, want false", valid, err)
+	}
+}
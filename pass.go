@@ -0,0 +1,113 @@
+package x402go
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// PassTerms describes an access pass sold by a payment option: paying once grants access
+// to every resource under Scope for Duration
+type PassTerms struct {
+	// Duration is how long the pass grants access, in seconds
+	Duration int64 `json:"duration" yaml:"duration"`
+
+	// Scope is the path prefix the pass grants access to (default: the path of the paid resource)
+	Scope string `json:"scope,omitempty" yaml:"scope,omitempty"`
+}
+
+// Pass is an access pass issued by the middleware, carried by the client in the
+// X-Payment-Pass header as a signed token
+type Pass struct {
+	// Host and Scope are the host and path prefix the pass grants access to
+	Host  string `json:"host"`
+	Scope string `json:"scope"`
+
+	// Sender is the address that paid for the pass
+	Sender string `json:"sender"`
+
+	// Expiry is when the pass expires (Unix timestamp)
+	Expiry int64 `json:"expiry"`
+}
+
+// Covers reports whether the pass grants access to a request for path on host at now
+func (p *Pass) Covers(host, path string, now time.Time) bool {
+	if host != p.Host || now.Unix() >= p.Expiry {
+		return false
+	}
+	return inScope(path, p.Scope)
+}
+
+// inScope reports whether path lies under the path prefix scope, on a segment boundary
+func inScope(path, scope string) bool {
+	if path == scope {
+		return true
+	}
+	return strings.HasPrefix(path, strings.TrimSuffix(scope, "/")+"/")
+}
+
+// validate checks that the terms describe a usable pass
+func (t *PassTerms) validate() error {
+	if t.Duration <= 0 {
+		return fmt.Errorf("pass duration must be positive")
+	}
+	if t.Scope != "" && !strings.HasPrefix(t.Scope, "/") {
+		return fmt.Errorf("pass scope %q is not an absolute path", t.Scope)
+	}
+	return nil
+}
+
+// readPass returns the valid pass presented with a request, or nil
+func readPass(r *http.Request, config *MiddlewareConfig) *Pass {
+	token := r.Header.Get(HeaderPaymentPass)
+	if token == "" || len(config.PassKey) == 0 {
+		return nil
+	}
+
+	var pass Pass
+	if err := verifyToken(config.PassKey, tokenPass, token, &pass); err != nil {
+		return nil
+	}
+	if !pass.Covers(r.Host, r.URL.Path, time.Now()) {
+		return nil
+	}
+	return &pass
+}
+
+// servePass serves a request under an access pass
+func servePass(w http.ResponseWriter, r *http.Request, pass *Pass, next http.Handler) {
+	paymentCtx := &PaymentContext{
+		Payment: Payment{
+			Sender: pass.Sender,
+		},
+		Verified:   true,
+		VerifiedAt: time.Now(),
+		Pass:       pass,
+	}
+	next.ServeHTTP(w, r.WithContext(withPaymentContext(r.Context(), paymentCtx)))
+}
+
+// grantPass adds an access pass to the response if the paid option sells one
+func grantPass(w http.ResponseWriter, r *http.Request, config *MiddlewareConfig, pc *PaymentContext) {
+	terms := pc.Requirements.Pass
+	if terms == nil || len(config.PassKey) == 0 {
+		return
+	}
+
+	scope := terms.Scope
+	if scope == "" {
+		scope = r.URL.Path
+	}
+
+	token, err := signToken(config.PassKey, tokenPass, &Pass{
+		Host:   r.Host,
+		Scope:  scope,
+		Sender: pc.Payment.Sender,
+		Expiry: time.Now().Add(time.Duration(terms.Duration) * time.Second).Unix(),
+	})
+	if err != nil {
+		return
+	}
+	w.Header().Set(HeaderPaymentPass, token)
+}
@@ -0,0 +1,161 @@
+package x402go
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPassCovers(t *testing.T) {
+	now := time.Now()
+	pass := &Pass{Host: "example.com", Scope: "/datasets/", Expiry: now.Add(time.Hour).Unix()}
+
+	tests := []struct {
+		name string
+		host string
+		path string
+		now  time.Time
+		want bool
+	}{
+		{"scope itself", "example.com", "/datasets/", now, true},
+		{"scope without slash", "example.com", "/datasets", now, false},
+		{"under scope", "example.com", "/datasets/weather.csv", now, true},
+		{"sibling prefix", "example.com", "/datasets-private/a", now, false},
+		{"outside scope", "example.com", "/admin", now, false},
+		{"other host", "other.example.com", "/datasets/a", now, false},
+		{"expired", "example.com", "/datasets/a", now.Add(time.Hour), false},
+	}
+	for _, tt := range tests {
+		if got := pass.Covers(tt.host, tt.path, tt.now); got != tt.want {
+			t.Errorf("%s: Covers(%s, %s) = %v, want %v", tt.name, tt.host, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestPassTermsValidate(t *testing.T) {
+	tests := []struct {
+		terms   PassTerms
+		wantErr bool
+	}{
+		{PassTerms{Duration: 3600}, false},
+		{PassTerms{Duration: 3600, Scope: "/datasets/"}, false},
+		{PassTerms{Duration: 0}, true},
+		{PassTerms{Duration: -1}, true},
+		{PassTerms{Duration: 3600, Scope: "datasets"}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.terms.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate(%+v) = %v, want error %v", tt.terms, err, tt.wantErr)
+		}
+	}
+}
+
+func TestPassIssueAndVerify(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	config := &MiddlewareConfig{PassKey: key}
+
+	// issue grants a pass for a payment made on path, with the given terms
+	issue := func(terms *PassTerms, path string) string {
+		requirements := testRequirements()
+		requirements.Pass = terms
+		rec := httptest.NewRecorder()
+		grantPass(rec, httptest.NewRequest("GET", "http://example.com"+path, nil), config, &PaymentContext{
+			Payment:      Payment{Sender: "0xabc"},
+			Requirements: requirements,
+		})
+		return rec.Header().Get(HeaderPaymentPass)
+	}
+
+	scoped := issue(&PassTerms{Duration: 3600, Scope: "/datasets/"}, "/datasets/a")
+	unscoped := issue(&PassTerms{Duration: 3600}, "/reports/1")
+	if scoped == "" || unscoped == "" {
+		t.Fatal("no pass granted")
+	}
+	if token := issue(nil, "/datasets/a"); token != "" {
+		t.Fatal("pass granted for an option that sells none")
+	}
+
+	expired, err := signToken(key, tokenPass, &Pass{Host: "example.com", Scope: "/", Sender: "0xabc", Expiry: time.Now().Add(-time.Second).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged, err := signToken([]byte("fedcba9876543210"), tokenPass, &Pass{Host: "example.com", Scope: "/", Sender: "0xabc", Expiry: time.Now().Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := signToken(key, tokenSession, &Pass{Host: "example.com", Scope: "/", Sender: "0xabc", Expiry: time.Now().Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		url   string
+		want  bool
+	}{
+		{"in scope", scoped, "http://example.com/datasets/b", true},
+		{"out of scope", scoped, "http://example.com/reports/1", false},
+		{"other host", scoped, "http://other.example.com/datasets/b", false},
+		{"defaults to the paid path", unscoped, "http://example.com/reports/1", true},
+		{"outside the paid path", unscoped, "http://example.com/reports/2", false},
+		{"expired", expired, "http://example.com/datasets/b", false},
+		{"signed with another key", forged, "http://example.com/datasets/b", false},
+		{"token of another kind", session, "http://example.com/datasets/b", false},
+		{"none", "", "http://example.com/datasets/b", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.url, nil)
+			r.Header.Set(HeaderPaymentPass, tt.token)
+			pass := readPass(r, config)
+			if (pass != nil) != tt.want {
+				t.Fatalf("readPass() = %+v, want valid %v", pass, tt.want)
+			}
+			if pass != nil && pass.Sender != "0xabc" {
+				t.Errorf("pass sender = %q, want 0xabc", pass.Sender)
+			}
+		})
+	}
+}
+
+func TestPassServedByMiddleware(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	requirements := testRequirements()
+	requirements.Pass = &PassTerms{Duration: 3600, Scope: "/datasets/"}
+
+	var served *PaymentContext
+	handler := RequirePaymentWithConfig(&MiddlewareConfig{
+		Requirements: requirements,
+		PassKey:      key,
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served, _ = GetPayment(r)
+	}))
+
+	token, err := signToken(key, tokenPass, &Pass{Host: "example.com", Scope: "/datasets/", Sender: "0xabc", Expiry: time.Now().Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		path   string
+		status int
+	}{
+		{"/datasets/a", http.StatusOK},
+		{"/other", http.StatusPaymentRequired},
+	} {
+		served = nil
+		r := httptest.NewRequest("GET", "http://example.com"+tt.path, nil)
+		r.Header.Set(HeaderPaymentPass, token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+
+		if rec.Code != tt.status {
+			t.Fatalf("%s: status = %d, want %d", tt.path, rec.Code, tt.status)
+		}
+		if tt.status == http.StatusOK && (served == nil || served.Pass == nil || served.Payment.Sender != "0xabc") {
+			t.Errorf("%s: served with %+v, want the pass's payment context", tt.path, served)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package x402go
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PaywallMux charges for requests matching priced route patterns and passes requests
+// for paths no pattern matches through to the wrapped handler for free. Requests for a
+// priced path with a method its patterns do not name are answered 405. Patterns use
+// the http.ServeMux syntax of Go 1.22, e.g. "GET /reports/{id}" or "/api/premium/".
+type PaywallMux struct {
+	mux    *http.ServeMux
+	next   http.Handler
+	config MiddlewareConfig
+
+	// methods holds the methods named by priced patterns
+	methods map[string]bool
+}
+
+// PaywallRoute prices the requests matching a pattern
+type PaywallRoute struct {
+	// Pattern is an http.ServeMux pattern, optionally with a method and wildcards
+	Pattern string `json:"pattern" yaml:"pattern"`
+
+	// Accepts lists the payment options for the route, the preferred one first
+	Accepts []*PaymentRequirements `json:"accepts" yaml:"accepts"`
+}
+
+// PaywallConfig is a route table of prices, typically loaded from a file
+type PaywallConfig struct {
+	Routes []PaywallRoute `json:"routes" yaml:"routes"`
+}
+
+// NewPaywallMux creates a PaywallMux in front of next. config is the template for the
+// middleware of every priced route; its Requirements, Accepts and RequirementsFunc are
+// ignored. All routes share one NonceStore, an in-memory one if config sets none.
+func NewPaywallMux(config *MiddlewareConfig, next http.Handler) *PaywallMux {
+	m := &PaywallMux{
+		mux:     http.NewServeMux(),
+		next:    next,
+		methods: make(map[string]bool),
+	}
+	if config != nil {
+		m.config = *config
+	}
+	m.config.Requirements = nil
+	m.config.Accepts = nil
+	m.config.RequirementsFunc = nil
+	if m.config.NonceStore == nil {
+		m.config.NonceStore = NewMemoryNonceStore()
+	}
+	return m
+}
+
+// Handle prices the requests matching pattern with the given payment options
+func (m *PaywallMux) Handle(pattern string, options ...*PaymentRequirements) error {
+	if len(options) == 0 {
+		return fmt.Errorf("route %q has no payment options", pattern)
+	}
+
+	config := m.config
+	config.Requirements = options[0]
+	config.Accepts = options[1:]
+	return m.register(pattern, &config)
+}
+
+// HandlePricing prices the requests matching pattern per request with price
+func (m *PaywallMux) HandlePricing(pattern string, price RequirementsFunc) error {
+	if price == nil {
+		return fmt.Errorf("route %q has no pricing function", pattern)
+	}
+
+	config := m.config
+	config.RequirementsFunc = price
+	return m.register(pattern, &config)
+}
+
+// HandleConfig prices every route of a route table
+func (m *PaywallMux) HandleConfig(config *PaywallConfig) error {
+	for _, route := range config.Routes {
+		if err := m.Handle(route.Pattern, route.Accepts...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// register adds the payment middleware for pattern, reporting invalid or
+// conflicting patterns as errors rather than panics
+func (m *PaywallMux) register(pattern string, config *MiddlewareConfig) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("invalid route %q: %v", pattern, r)
+		}
+	}()
+
+	m.mux.Handle(pattern, RequirePaymentWithConfig(config, m.next))
+	if method, _, ok := strings.Cut(pattern, " "); ok {
+		m.methods[method] = true
+	}
+	return nil
+}
+
+// ServeHTTP implements http.Handler
+func (m *PaywallMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h, pattern := m.mux.Handler(r)
+	if pattern == "" {
+		// A priced path requested with another method is not free
+		if allowed := m.allowedMethods(r); len(allowed) > 0 {
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Requests matching no priced route are free
+		m.next.ServeHTTP(w, r)
+		return
+	}
+	h.ServeHTTP(w, r)
+}
+
+// allowedMethods returns the methods with which a priced route matches the request's path
+func (m *PaywallMux) allowedMethods(r *http.Request) []string {
+	var allowed []string
+	for method := range m.methods {
+		probe := r.Clone(r.Context())
+		probe.Method = method
+		if _, pattern := m.mux.Handler(probe); pattern != "" {
+			allowed = append(allowed, method)
+		}
+	}
+	sort.Strings(allowed)
+	return allowed
+}
+
+// LoadPaywallConfig reads a route table from a JSON or, for .yaml and .yml files, YAML file
+func LoadPaywallConfig(path string) (*PaywallConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read paywall config: %w", err)
+	}
+
+	var config PaywallConfig
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &config)
+	default:
+		err = json.Unmarshal(data, &config)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse paywall config: %w", err)
+	}
+
+	return &config, nil
+}
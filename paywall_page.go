@@ -0,0 +1,176 @@
+package x402go
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// PaywallPage configures the HTML page sent instead of the JSON body when a browser
+// requests a paid resource
+type PaywallPage struct {
+	// Template renders the page from PaywallData (optional, defaults to a built-in page)
+	Template *template.Template
+
+	// Title is the page title (default: "Payment Required")
+	Title string
+
+	// Script is trusted HTML inserted at the end of the page body, e.g. a wallet-connect
+	// script that pays using the requirements in PaywallData.PaymentRequired (optional)
+	Script template.HTML
+}
+
+// PaywallData is what the paywall template is executed with
+type PaywallData struct {
+	Title       string
+	Resource    string
+	Description string
+
+	// Options are the payment options, the preferred one first
+	Options []PaywallOption
+
+	// PaymentRequired is the JSON body API clients receive, for use in scripts
+	PaymentRequired template.JS
+
+	// Script is PaywallPage.Script
+	Script template.HTML
+}
+
+// PaywallOption describes a payment option for display
+type PaywallOption struct {
+	// Price is the formatted amount, e.g. "0.01 USDC", or base units for unknown tokens
+	Price string
+
+	// Token is the token contract address
+	Token string
+
+	// Network is the v1 network name of the chain, e.g. "base"
+	Network string
+
+	// Recipient is the address the payment goes to
+	Recipient string
+
+	// Requirements are the issued requirements behind the option
+	Requirements *PaymentRequirements
+}
+
+// defaultPaywallTemplate is the page rendered when PaywallPage.Template is not set
+var defaultPaywallTemplate = template.Must(template.New("paywall").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 40rem; margin: 4rem auto; padding: 0 1rem; color: #222; }
+.option { border: 1px solid #ddd; border-radius: 6px; padding: 1rem; margin: 1rem 0; }
+.price { font-size: 1.5rem; font-weight: 600; }
+dt { color: #666; font-size: 0.85rem; margin-top: 0.5rem; }
+dd { margin: 0; font-family: ui-monospace, monospace; word-break: break-all; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{if .Description}}<p>{{.Description}}</p>{{end}}
+<p>Access to <code>{{.Resource}}</code> requires a payment.</p>
+{{range .Options}}<div class="option">
+<div class="price">{{.Price}}</div>
+<dl>
+<dt>Network</dt><dd>{{.Network}}</dd>
+<dt>Token</dt><dd>{{.Token}}</dd>
+<dt>Recipient</dt><dd>{{.Recipient}}</dd>
+</dl>
+</div>
+{{end}}<script id="x402-payment-required" type="application/json">{{.PaymentRequired}}</script>
+{{.Script}}
+</body>
+</html>
+`))
+
+// prefersHTML reports whether the request's Accept header ranks HTML above JSON, as
+// browsers do
+func prefersHTML(r *http.Request) bool {
+	var htmlQ, jsonQ float64
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if s, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(s, 64); err == nil {
+				q = parsed
+			}
+		}
+
+		switch mediaType {
+		case "text/html", "application/xhtml+xml":
+			htmlQ = max(htmlQ, q)
+		case "application/json":
+			jsonQ = max(jsonQ, q)
+		}
+	}
+	return htmlQ > 0 && htmlQ > jsonQ
+}
+
+// sendPaywallPage renders the paywall page for issued requirements with a 402 status.
+// body is the JSON body API clients receive.
+func sendPaywallPage(w http.ResponseWriter, config *MiddlewareConfig, issued []*PaymentRequirements, body interface{}) {
+	page := config.Paywall
+	if page == nil {
+		page = &PaywallPage{}
+	}
+
+	tmpl := page.Template
+	if tmpl == nil {
+		tmpl = defaultPaywallTemplate
+	}
+
+	// json.Marshal escapes <, > and &, so the body is safe inside a script element
+	paymentRequired, err := json.Marshal(body)
+	if err != nil {
+		http.Error(w, "Failed to generate payment requirements", http.StatusInternalServerError)
+		return
+	}
+
+	data := &PaywallData{
+		Title:           page.Title,
+		Resource:        issued[0].Resource,
+		Description:     issued[0].Description,
+		PaymentRequired: template.JS(paymentRequired),
+		Script:          page.Script,
+	}
+	if data.Title == "" {
+		data.Title = "Payment Required"
+	}
+	for _, requirements := range issued {
+		amount, err := ParseAmount(requirements.Amount)
+		price := requirements.Amount
+		if err == nil {
+			price = DefaultTokens.FormatAmount(requirements.Chain, requirements.Token, amount)
+		}
+		data.Options = append(data.Options, PaywallOption{
+			Price:        price,
+			Token:        requirements.Token,
+			Network:      NetworkForChain(requirements.Chain),
+			Recipient:    requirements.Recipient,
+			Requirements: requirements,
+		})
+	}
+
+	// Render fully before writing so template errors do not produce half a page
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		http.Error(w, "Failed to render paywall", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusPaymentRequired)
+	w.Write(buf.Bytes())
+}
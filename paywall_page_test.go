@@ -0,0 +1,130 @@
+package x402go
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPrefersHTML(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", true},
+		{"application/xhtml+xml", true},
+		{"text/html", true},
+		{"application/json", false},
+		{"application/json, text/html", false},
+		{"text/html, application/json", false},
+		{"text/html;q=0.9, application/json", false},
+		{"text/html, application/json;q=0.9", true},
+		{"text/html;q=0", false},
+		{"text/html;q=0.5, application/json;q=0.1", true},
+		{"*/*", false},
+		{"", false},
+		{"not a media type, text/html", true},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept", tt.accept)
+		if got := prefersHTML(r); got != tt.want {
+			t.Errorf("prefersHTML(%q) = %v, want %v", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestPaywallPage(t *testing.T) {
+	requirements := testRequirements()
+	requirements.Amount = "1500000"
+	requirements.Description = "Weather <data>"
+
+	tests := []struct {
+		name        string
+		accept      string
+		page        *PaywallPage
+		status      int
+		contentType string
+		contains    []string
+		excludes    []string
+	}{
+		{
+			name:        "json",
+			accept:      "application/json",
+			status:      http.StatusPaymentRequired,
+			contentType: "application/json",
+			excludes:    []string{"<html"},
+		},
+		{
+			name:        "default page",
+			accept:      "text/html",
+			status:      http.StatusPaymentRequired,
+			contentType: "text/html; charset=utf-8",
+			contains: []string{
+				"<title>Payment Required</title>",
+				"1.5 USDC",
+				"Weather &lt;data&gt;",
+				"0x1111111111111111111111111111111111111111",
+				`<script id="x402-payment-required" type="application/json">`,
+				`"maxAmountRequired":"1500000"`,
+			},
+		},
+		{
+			name:        "custom title and script",
+			accept:      "text/html",
+			page:        &PaywallPage{Title: "Premium", Script: `<script src="/wallet.js"></script>`},
+			status:      http.StatusPaymentRequired,
+			contentType: "text/html; charset=utf-8",
+			contains:    []string{"<title>Premium</title>", `<script src="/wallet.js"></script>`},
+		},
+		{
+			name:        "custom template",
+			accept:      "text/html",
+			page:        &PaywallPage{Template: template.Must(template.New("p").Parse(`<p>{{range .Options}}{{.Price}} on {{.Network}}{{end}}</p>`))},
+			status:      http.StatusPaymentRequired,
+			contentType: "text/html; charset=utf-8",
+			contains:    []string{"<p>1.5 USDC on base</p>"},
+		},
+		{
+			name:     "failing template",
+			accept:   "text/html",
+			page:     &PaywallPage{Template: template.Must(template.New("p").Parse(`<h1>partial</h1>{{.Title.Missing}}`))},
+			status:   http.StatusInternalServerError,
+			excludes: []string{"partial"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := RequirePaymentWithConfig(&MiddlewareConfig{
+				Requirements: requirements,
+				Paywall:      tt.page,
+			}, http.NotFoundHandler())
+
+			r := httptest.NewRequest("GET", "http://example.com/weather", nil)
+			r.Header.Set("Accept", tt.accept)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, r)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.contentType != "" && rec.Header().Get("Content-Type") != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", rec.Header().Get("Content-Type"), tt.contentType)
+			}
+			body := rec.Body.String()
+			for _, s := range tt.contains {
+				if !strings.Contains(body, s) {
+					t.Errorf("body lacks %q:\n%s", s, body)
+				}
+			}
+			for _, s := range tt.excludes {
+				if strings.Contains(body, s) {
+					t.Errorf("body contains %q:\n%s", s, body)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package x402go
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPaywallMuxRouting(t *testing.T) {
+	paywall := NewPaywallMux(nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	if err := paywall.Handle("GET /reports/{id}", testRequirements()); err != nil {
+		t.Fatal(err)
+	}
+	if err := paywall.Handle("/premium/", testRequirements()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method    string
+		path      string
+		want      int
+		wantAllow string
+	}{
+		{"GET", "/reports/1", http.StatusPaymentRequired, ""},
+		{"HEAD", "/reports/1", http.StatusPaymentRequired, ""},
+		{"POST", "/reports/1", http.StatusMethodNotAllowed, "GET"},
+		{"DELETE", "/reports/1", http.StatusMethodNotAllowed, "GET"},
+		{"POST", "/premium/x", http.StatusPaymentRequired, ""},
+		{"GET", "/free", http.StatusOK, ""},
+		{"POST", "/reports", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			paywall.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+			if rec.Code != tt.want {
+				t.Fatalf("status %d, want %d", rec.Code, tt.want)
+			}
+			if allow := rec.Header().Get("Allow"); allow != tt.wantAllow {
+				t.Fatalf("Allow %q, want %q", allow, tt.wantAllow)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package x402go
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrQuoteInvalid is returned when a payment's signed quote is missing or was not issued by this server
+var ErrQuoteInvalid = errors.New("quote invalid")
+
+// quoteExtraKey is the requirements Extra key carrying the signed quote
+const quoteExtraKey = "quote"
+
+// QuoteSigner signs the quotes in 402 responses with an HMAC key so the middleware can
+// verify the terms a payment was made against without storing every issued nonce
+type QuoteSigner struct {
+	key []byte
+}
+
+// NewQuoteSigner creates a QuoteSigner. key must be a secret of at least 16 bytes shared
+// by every server instance that verifies payments.
+func NewQuoteSigner(key []byte) (*QuoteSigner, error) {
+	if len(key) < minTokenKeyLength {
+		return nil, fmt.Errorf("quote signing key must be at least %d bytes", minTokenKeyLength)
+	}
+	return &QuoteSigner{key: append([]byte(nil), key...)}, nil
+}
+
+// Sign returns the signed token of a quote
+func (s *QuoteSigner) Sign(quote *Quote) (string, error) {
+	return signToken(s.key, tokenQuote, quote)
+}
+
+// Verify returns the quote of a signed token. It fails with ErrQuoteInvalid if the token
+// was not signed with the signer's key and ErrNonceExpired if the quote has expired.
+func (s *QuoteSigner) Verify(token string) (*Quote, error) {
+	if token == "" {
+		return nil, fmt.Errorf("%w: payment carries no quote", ErrQuoteInvalid)
+	}
+
+	var quote Quote
+	if err := verifyToken(s.key, tokenQuote, token, &quote); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrQuoteInvalid, err)
+	}
+	if time.Now().After(quote.Expiry) {
+		return nil, ErrNonceExpired
+	}
+	return &quote, nil
+}
+
+// QuoteToken returns the signed quote carried in requirements, if any
+func QuoteToken(requirements *PaymentRequirements) string {
+	token, _ := requirements.Extra[quoteExtraKey].(string)
+	return token
+}
+
+// withQuoteToken returns a copy of requirements carrying a signed quote in Extra
+func withQuoteToken(requirements *PaymentRequirements, token string) *PaymentRequirements {
+	quoted := *requirements
+	quoted.Extra = make(map[string]interface{}, len(requirements.Extra)+1)
+	for k, v := range requirements.Extra {
+		quoted.Extra[k] = v
+	}
+	quoted.Extra[quoteExtraKey] = token
+	return &quoted
+}
@@ -0,0 +1,217 @@
+package x402go
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestSignedQuoteRedeemOnce(t *testing.T) {
+	signer, err := NewQuoteSigner([]byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The middleware keeps a store of redeemed nonces even though quotes are signed
+	config := &MiddlewareConfig{
+		Requirements: &PaymentRequirements{Scheme: SchemeExact, Amount: "1000", Chain: "8453", Token: "0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913", Recipient: "0x1111111111111111111111111111111111111111"},
+		QuoteSigner:  signer,
+	}
+	RequirePaymentWithConfig(config, http.NotFoundHandler())
+	if config.NonceStore == nil {
+		t.Fatal("signed quotes are configured without a nonce store")
+	}
+
+	quote := &Quote{Resource: "/r", Expiry: time.Now().Add(time.Minute)}
+	if err := redeemNonce(config, "n1", quote); err != nil {
+		t.Fatalf("first redemption: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := redeemNonce(config, "n1", quote); !errors.Is(err, ErrNonceConsumed) {
+			t.Fatalf("replay %d: redeemNonce() = %v, want %v", i+1, err, ErrNonceConsumed)
+		}
+	}
+}
+
+func TestQuoteSignerVerify(t *testing.T) {
+	signer, err := NewQuoteSigner([]byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewQuoteSigner([]byte("fedcba9876543210"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(s *QuoteSigner, expiry time.Duration) string {
+		token, err := s.Sign(&Quote{Resource: "/r", Expiry: time.Now().Add(expiry)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{"valid", sign(signer, time.Minute), nil},
+		{"expired", sign(signer, -time.Second), ErrNonceExpired},
+		{"other key", sign(other, time.Minute), ErrQuoteInvalid},
+		{"missing", "", ErrQuoteInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := signer.Verify(tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRequirementsFuncErrors(t *testing.T) {
+	invalid := testRequirements()
+	invalid.Recipient = "0xYourAddress"
+	pass := testRequirements()
+	pass.Pass = &PassTerms{Duration: 3600}
+
+	tests := []struct {
+		name  string
+		price RequirementsFunc
+	}{
+		{"error", func(*http.Request) ([]*PaymentRequirements, error) { return nil, errors.New("price feed down") }},
+		{"no options", func(*http.Request) ([]*PaymentRequirements, error) { return nil, nil }},
+		{"only nil options", func(*http.Request) ([]*PaymentRequirements, error) { return []*PaymentRequirements{nil}, nil }},
+		{"invalid option", func(*http.Request) ([]*PaymentRequirements, error) {
+			return []*PaymentRequirements{testRequirements(), invalid}, nil
+		}},
+		{"pass without a pass key", func(*http.Request) ([]*PaymentRequirements, error) { return []*PaymentRequirements{pass}, nil }},
+	}
+	for _, tt := range tests {
+		handler := RequirePaymentWithConfig(&MiddlewareConfig{RequirementsFunc: tt.price}, http.NotFoundHandler())
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "http://example.com/r", nil))
+		if rec.Code != http.StatusInternalServerError {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, http.StatusInternalServerError)
+		}
+	}
+}
+
+func TestPricedQuote(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := NewQuoteSigner([]byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each request costs 1000 units per n
+	var paid *PaymentContext
+	server := httptest.NewServer(RequirePaymentWithConfig(&MiddlewareConfig{
+		RequirementsFunc: func(r *http.Request) ([]*PaymentRequirements, error) {
+			n, err := strconv.Atoi(r.URL.Query().Get("n"))
+			if err != nil {
+				return nil, err
+			}
+			requirements := testRequirements()
+			requirements.Amount = strconv.Itoa(n * 1000)
+			return []*PaymentRequirements{requirements}, nil
+		},
+		QuoteSigner: signer,
+		Verifier:    &EIP3009Verifier{},
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paid, _ = GetPayment(r)
+	})))
+	defer server.Close()
+
+	// quote returns the option quoted for n
+	quote := func(n int) *PaymentRequirements {
+		resp, err := http.Get(server.URL + "/r?n=" + strconv.Itoa(n))
+		if err != nil {
+			t.Fatal(err)
+		}
+		options, _, err := parsePaymentRequired(resp)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return options[0]
+	}
+
+	// The signed token carries the priced options and the resource
+	three := quote(3)
+	signed, err := signer.Verify(QuoteToken(three))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if three.Amount != "3000" || len(signed.Options) != 1 || signed.Options[0].Amount != "3000" || signed.Options[0].Nonce != three.Nonce {
+		t.Fatalf("quoted %s, signed options %+v, want 3000", three.Amount, signed.Options)
+	}
+	if signed.Resource != server.URL+"/r?n=3" {
+		t.Errorf("signed resource %q, want %q", signed.Resource, server.URL+"/r?n=3")
+	}
+
+	// A client pays the priced amount
+	resp, err := NewClientWithSigner(NewPrivateKeySigner(key)).Get(server.URL + "/r?n=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || paid == nil || paid.Requirements.Amount != "2000" {
+		t.Fatalf("status %d, paid %+v, want 2000 paid", resp.StatusCode, paid)
+	}
+
+	// send pays requirements with the given quote token on path
+	send := func(requirements *PaymentRequirements, token, path string) *http.Response {
+		payment, err := NewSignerPaymentHandler(NewPrivateKeySigner(key), nil)(context.Background(), requirements)
+		if err != nil {
+			t.Fatal(err)
+		}
+		payment.Quote = token
+		header, err := EncodeHeaderV1(payment.ToV1())
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, _ := http.NewRequest("GET", server.URL+path, nil)
+		req.Header.Set(HeaderPayment, header)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	// The quoted price holds, not one the client edits in
+	one := quote(1)
+	cheap := *three
+	cheap.Amount = "1000"
+	tests := []struct {
+		name         string
+		requirements *PaymentRequirements
+		token        string
+		path         string
+		want         RejectionReason
+	}{
+		{"quote for another resource", one, QuoteToken(one), "/r?n=3", RejectWrongResource},
+		{"amount edited below the quote", &cheap, QuoteToken(three), "/r?n=3", RejectWrongAmount},
+		{"forged quote", three, QuoteToken(three) + "x", "/r?n=3", RejectInvalidQuote},
+	}
+	for _, tt := range tests {
+		resp := send(tt.requirements, tt.token, tt.path)
+		if resp.StatusCode != http.StatusPaymentRequired || RejectionReason(resp.Header.Get(HeaderPaymentError)) != tt.want {
+			t.Errorf("%s: status %d (%s), want 402 (%s)", tt.name, resp.StatusCode, resp.Header.Get(HeaderPaymentError), tt.want)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package registry
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ChecksumAddress validates a hex address and returns it in EIP-55 checksum form.
+// All-lowercase and all-uppercase addresses carry no checksum and are accepted;
+// mixed-case addresses must have a valid checksum.
+func ChecksumAddress(address string) (string, error) {
+	if !common.IsHexAddress(address) || !strings.HasPrefix(address, "0x") {
+		return "", fmt.Errorf("invalid address %q", address)
+	}
+
+	checksummed := common.HexToAddress(address).Hex()
+	digits := address[2:]
+	if digits != strings.ToLower(digits) && digits != strings.ToUpper(digits) && address != checksummed {
+		return "", fmt.Errorf("address %q has an invalid EIP-55 checksum", address)
+	}
+	return checksummed, nil
+}
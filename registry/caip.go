@@ -0,0 +1,82 @@
+package registry
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// NamespaceEIP155 is the CAIP-2 namespace of EVM chains
+const NamespaceEIP155 = "eip155"
+
+// AssetNamespaceERC20 is the CAIP-19 asset namespace of ERC-20 tokens
+const AssetNamespaceERC20 = "erc20"
+
+var (
+	caipNamespace        = regexp.MustCompile(`^[-a-z0-9]{3,8}$`)
+	caip2Reference       = regexp.MustCompile(`^[-_a-zA-Z0-9]{1,32}$`)
+	caip19AssetReference = regexp.MustCompile(`^[-.%a-zA-Z0-9]{1,128}$`)
+)
+
+// ChainID is a CAIP-2 chain identifier, e.g. eip155:8453
+type ChainID struct {
+	Namespace string
+	Reference string
+}
+
+// ParseChainID parses a CAIP-2 chain identifier
+func ParseChainID(s string) (ChainID, error) {
+	namespace, reference, ok := strings.Cut(s, ":")
+	if !ok || !caipNamespace.MatchString(namespace) || !caip2Reference.MatchString(reference) {
+		return ChainID{}, fmt.Errorf("invalid CAIP-2 chain id %q", s)
+	}
+	return ChainID{Namespace: namespace, Reference: reference}, nil
+}
+
+// EIP155 returns the CAIP-2 identifier of an EVM chain
+func EIP155(chainID string) ChainID {
+	return ChainID{Namespace: NamespaceEIP155, Reference: chainID}
+}
+
+// String returns the identifier in CAIP-2 form
+func (c ChainID) String() string {
+	return c.Namespace + ":" + c.Reference
+}
+
+// AssetID is a CAIP-19 asset type identifier, e.g.
+// eip155:8453/erc20:0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913
+type AssetID struct {
+	Chain     ChainID
+	Namespace string
+	Reference string
+}
+
+// ParseAssetID parses a CAIP-19 asset type identifier
+func ParseAssetID(s string) (AssetID, error) {
+	chain, asset, ok := strings.Cut(s, "/")
+	if !ok {
+		return AssetID{}, fmt.Errorf("invalid CAIP-19 asset id %q", s)
+	}
+
+	chainID, err := ParseChainID(chain)
+	if err != nil {
+		return AssetID{}, fmt.Errorf("invalid CAIP-19 asset id %q: %w", s, err)
+	}
+
+	namespace, reference, ok := strings.Cut(asset, ":")
+	if !ok || !caipNamespace.MatchString(namespace) || !caip19AssetReference.MatchString(reference) {
+		return AssetID{}, fmt.Errorf("invalid CAIP-19 asset id %q", s)
+	}
+
+	return AssetID{Chain: chainID, Namespace: namespace, Reference: reference}, nil
+}
+
+// ERC20 returns the CAIP-19 identifier of an ERC-20 token on an EVM chain
+func ERC20(chainID, address string) AssetID {
+	return AssetID{Chain: EIP155(chainID), Namespace: AssetNamespaceERC20, Reference: address}
+}
+
+// String returns the identifier in CAIP-19 form
+func (a AssetID) String() string {
+	return a.Chain.String() + "/" + a.Namespace + ":" + a.Reference
+}
@@ -0,0 +1,186 @@
+// Package registry knows the networks and tokens x402 payments are made on. Networks are
+// identified by EIP-155 chain ID, v1 network name or CAIP-2 id, and tokens by CAIP-19 asset id.
+package registry
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Network is an EVM network
+type Network struct {
+	// Name is the network name used by the x402 v1 wire format (e.g. "base")
+	Name string
+
+	// ChainID is the EIP-155 chain ID (e.g. "8453")
+	ChainID string
+
+	// Testnet reports whether the network is a test network
+	Testnet bool
+}
+
+// CAIP2 returns the network's CAIP-2 identifier
+func (n Network) CAIP2() ChainID {
+	return EIP155(n.ChainID)
+}
+
+// Token is an ERC-20 payment token
+type Token struct {
+	// Asset is the token's CAIP-19 identifier
+	Asset AssetID
+
+	// Symbol is the token's ticker symbol (e.g. "USDC")
+	Symbol string
+
+	// Decimals is the number of decimals of the token's base unit
+	Decimals int
+}
+
+// ChainID returns the EIP-155 chain ID the token is deployed on
+func (t Token) ChainID() string {
+	return t.Asset.Chain.Reference
+}
+
+// Address returns the token contract address
+func (t Token) Address() string {
+	return t.Asset.Reference
+}
+
+var decimalChainID = regexp.MustCompile(`^[0-9]+$`)
+
+var (
+	mu sync.RWMutex
+
+	networks = []Network{
+		{Name: "ethereum", ChainID: "1"},
+		{Name: "sepolia", ChainID: "11155111", Testnet: true},
+		{Name: "base", ChainID: "8453"},
+		{Name: "base-sepolia", ChainID: "84532", Testnet: true},
+		{Name: "polygon", ChainID: "137"},
+		{Name: "polygon-amoy", ChainID: "80002", Testnet: true},
+		{Name: "avalanche", ChainID: "43114"},
+		{Name: "avalanche-fuji", ChainID: "43113", Testnet: true},
+		{Name: "iotex", ChainID: "4689"},
+		{Name: "sei", ChainID: "1329"},
+		{Name: "sei-testnet", ChainID: "1328", Testnet: true},
+		{Name: "peaq", ChainID: "3338"},
+	}
+
+	tokens = []Token{
+		{Asset: ERC20("1", "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"), Symbol: "USDC", Decimals: 6},
+		{Asset: ERC20("11155111", "0x1c7D4B196Cb0C7B01d743Fbc6116a902379C7238"), Symbol: "USDC", Decimals: 6},
+		{Asset: ERC20("8453", "0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913"), Symbol: "USDC", Decimals: 6},
+		{Asset: ERC20("84532", "0x036CbD53842c5426634e7929541eC2318f3dCF7e"), Symbol: "USDC", Decimals: 6},
+		{Asset: ERC20("137", "0x3c499c542cEF5E3811e1192ce70d8cC03d5c3359"), Symbol: "USDC", Decimals: 6},
+		{Asset: ERC20("80002", "0x41E94Eb019C0762f9Bfcf9Fb1E58725BfB0e7582"), Symbol: "USDC", Decimals: 6},
+		{Asset: ERC20("43114", "0xB97EF9Ef8734C71904D8002F8b6Bc66Dd9c48a6E"), Symbol: "USDC", Decimals: 6},
+		{Asset: ERC20("43113", "0x5425890298aed601595a70AB815c96711a31Bc65"), Symbol: "USDC", Decimals: 6},
+	}
+)
+
+// Networks returns all known networks
+func Networks() []Network {
+	mu.RLock()
+	defer mu.RUnlock()
+	return append([]Network(nil), networks...)
+}
+
+// LookupNetwork finds a known network by chain ID, v1 network name or CAIP-2 id
+func LookupNetwork(id string) (Network, bool) {
+	chainID, ok := EVMChainID(id)
+	if !ok {
+		return Network{}, false
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, network := range networks {
+		if network.ChainID == chainID {
+			return network, true
+		}
+	}
+	return Network{}, false
+}
+
+// RegisterNetwork adds a network, replacing any known network with the same chain ID or name
+func RegisterNetwork(network Network) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	kept := networks[:0]
+	for _, known := range networks {
+		if known.ChainID != network.ChainID && known.Name != network.Name {
+			kept = append(kept, known)
+		}
+	}
+	networks = append(kept, network)
+}
+
+// EVMChainID resolves a chain ID, a known v1 network name or an eip155 CAIP-2 id to an
+// EIP-155 chain ID. Unknown names are not resolved.
+func EVMChainID(id string) (string, bool) {
+	if decimalChainID.MatchString(id) {
+		return id, true
+	}
+
+	if chain, err := ParseChainID(id); err == nil {
+		if chain.Namespace == NamespaceEIP155 && decimalChainID.MatchString(chain.Reference) {
+			return chain.Reference, true
+		}
+		return "", false
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, network := range networks {
+		if network.Name == id {
+			return network.ChainID, true
+		}
+	}
+	return "", false
+}
+
+// Tokens returns all known tokens
+func Tokens() []Token {
+	mu.RLock()
+	defer mu.RUnlock()
+	return append([]Token(nil), tokens...)
+}
+
+// LookupToken finds a known token by chain, given as for LookupNetwork, and contract address
+func LookupToken(chain, address string) (Token, bool) {
+	chainID, ok := EVMChainID(chain)
+	if !ok {
+		return Token{}, false
+	}
+	return LookupAsset(ERC20(chainID, address))
+}
+
+// LookupAsset finds a known token by CAIP-19 asset id. Addresses are matched case-insensitively.
+func LookupAsset(asset AssetID) (Token, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, token := range tokens {
+		if token.Asset.Chain == asset.Chain && token.Asset.Namespace == asset.Namespace &&
+			strings.EqualFold(token.Asset.Reference, asset.Reference) {
+			return token, true
+		}
+	}
+	return Token{}, false
+}
+
+// RegisterToken adds a token, replacing any known token with the same asset id
+func RegisterToken(token Token) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	kept := tokens[:0]
+	for _, known := range tokens {
+		if known.Asset.Chain != token.Asset.Chain || known.Asset.Namespace != token.Asset.Namespace ||
+			!strings.EqualFold(known.Asset.Reference, token.Asset.Reference) {
+			kept = append(kept, known)
+		}
+	}
+	tokens = append(kept, token)
+}
@@ -0,0 +1,139 @@
+package registry
+
+import "testing"
+
+func TestParseChainID(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    ChainID
+		wantErr bool
+	}{
+		{"eip155:8453", ChainID{"eip155", "8453"}, false},
+		{"eip155:1", ChainID{"eip155", "1"}, false},
+		{"solana:5eykt4UsFv8P8NJdTREpY1vzqKqZKvdp", ChainID{"solana", "5eykt4UsFv8P8NJdTREpY1vzqKqZKvdp"}, false},
+		{"8453", ChainID{}, true},
+		{"eip155:", ChainID{}, true},
+		{":8453", ChainID{}, true},
+		{"ei:8453", ChainID{}, true},
+		{"EIP155:8453", ChainID{}, true},
+		{"eip155:84 53", ChainID{}, true},
+		{"eip155:" + "123456789012345678901234567890123", ChainID{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseChainID(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseChainID(%q) err = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseChainID(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+		if err == nil && got.String() != tt.in {
+			t.Errorf("ParseChainID(%q).String() = %q", tt.in, got.String())
+		}
+	}
+}
+
+func TestParseAssetID(t *testing.T) {
+	usdc := "eip155:8453/erc20:0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913"
+	tests := []struct {
+		in      string
+		want    AssetID
+		wantErr bool
+	}{
+		{usdc, ERC20("8453", "0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913"), false},
+		{"eip155:1/slip44:60", AssetID{Chain: EIP155("1"), Namespace: "slip44", Reference: "60"}, false},
+		{"eip155:8453", AssetID{}, true},
+		{"eip155:8453/erc20", AssetID{}, true},
+		{"eip155:8453/erc20:", AssetID{}, true},
+		{"8453/erc20:0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913", AssetID{}, true},
+		{"eip155:8453/ERC20:0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913", AssetID{}, true},
+		{"eip155:8453/erc20:0x83/3589", AssetID{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseAssetID(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseAssetID(%q) err = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseAssetID(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+		if err == nil && got.String() != tt.in {
+			t.Errorf("ParseAssetID(%q).String() = %q", tt.in, got.String())
+		}
+	}
+}
+
+func TestChecksumAddress(t *testing.T) {
+	const checksummed = "0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913"
+	tests := []struct {
+		in      string
+		wantErr bool
+	}{
+		{checksummed, false},
+		{"0x833589fcd6edb6e08f4c7c32d4f71b54bda02913", false},
+		{"0x833589FCD6EDB6E08F4C7C32D4F71B54BDA02913", false},
+		{"0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913"[:41] + "4", true},
+		{"0x833589FcD6eDb6E08f4c7C32D4f71b54bdA02913", true},
+		{"833589fCD6eDb6E08f4c7C32D4f71b54bdA02913", true},
+		{"0x833589fCD6eDb6E08f4c7C32D4f71b54bdA0291", true},
+		{"0xYourAddress", true},
+		{"", true},
+	}
+	for _, tt := range tests {
+		got, err := ChecksumAddress(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ChecksumAddress(%q) err = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got != checksummed {
+			t.Errorf("ChecksumAddress(%q) = %q, want %q", tt.in, got, checksummed)
+		}
+	}
+}
+
+func TestEVMChainID(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"8453", "8453", true},
+		{"base", "8453", true},
+		{"eip155:8453", "8453", true},
+		{"base-sepolia", "84532", true},
+		{"eip155:base", "", false},
+		{"solana:5eykt4UsFv8P8NJdTREpY1vzqKqZKvdp", "", false},
+		{"unknown", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := EVMChainID(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("EVMChainID(%q) = %q, %v, want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestLookupToken(t *testing.T) {
+	for _, chain := range []string{"8453", "base", "eip155:8453"} {
+		token, ok := LookupToken(chain, "0x833589FCD6EDB6E08F4C7C32D4F71B54BDA02913")
+		if !ok || token.Symbol != "USDC" || token.Decimals != 6 || token.ChainID() != "8453" {
+			t.Errorf("LookupToken(%s) = %+v, %v, want USDC on 8453", chain, token, ok)
+		}
+	}
+	if _, ok := LookupToken("1", "0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913"); ok {
+		t.Error("token found on the wrong chain")
+	}
+
+	// Every built-in token is on a known network and has a checksummed address
+	for _, token := range Tokens() {
+		if _, ok := LookupNetwork(token.Asset.Chain.String()); !ok {
+			t.Errorf("%s is on an unknown network", token.Asset)
+		}
+		if checksummed, err := ChecksumAddress(token.Address()); err != nil || checksummed != token.Address() {
+			t.Errorf("%s: address is not checksummed: %v", token.Asset, err)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package x402go
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/berhberhberh/x402go/internal/parse"
+)
+
+// RejectionReason classifies why the middleware rejected a payment
+type RejectionReason string
+
+const (
+	// RejectBadFormat means the payment header could not be decoded
+	RejectBadFormat RejectionReason = "bad_format"
+
+	// RejectUnknownNonce means the payment's nonce was never issued by this server
+	RejectUnknownNonce RejectionReason = "unknown_nonce"
+
+	// RejectInvalidQuote means the payment's signed quote is missing or forged
+	RejectInvalidQuote RejectionReason = "invalid_quote"
+
+	// RejectExpired means the quote or the payment's authorization has expired
+	RejectExpired RejectionReason = "expired"
+
+	// RejectReplayed means the payment's nonce was already redeemed
+	RejectReplayed RejectionReason = "replayed"
+
+	// RejectWrongResource means the payment was quoted for a different resource
+	RejectWrongResource RejectionReason = "wrong_resource"
+
+	// RejectNoMatchingOption means no quoted option has the payment's scheme, chain and token
+	RejectNoMatchingOption RejectionReason = "no_matching_option"
+
+	// RejectWrongAmount means the payment is below the amount of every matching option
+	// or does not cover the request
+	RejectWrongAmount RejectionReason = "wrong_amount"
+
+	// RejectVerificationFailed means the verifier rejected the payment for another reason
+	RejectVerificationFailed RejectionReason = "verification_failed"
+
+	// RejectFacilitatorError means the facilitator could not be reached to verify the payment
+	RejectFacilitatorError RejectionReason = "facilitator_error"
+)
+
+// PaymentRejection describes a payment the middleware rejected
+type PaymentRejection struct {
+	// Reason classifies the rejection
+	Reason RejectionReason
+
+	// Payment is the rejected payment, nil when it could not be decoded
+	Payment *Payment
+
+	// Err is the underlying error, if any
+	Err error
+
+	// Status is the status code of the default response
+	Status int
+
+	// Message is the body of the default response
+	Message string
+}
+
+// Error implements error
+func (rj *PaymentRejection) Error() string {
+	if rj.Err != nil {
+		return fmt.Sprintf("payment rejected (%s): %v", rj.Reason, rj.Err)
+	}
+	return fmt.Sprintf("payment rejected (%s)", rj.Reason)
+}
+
+// Unwrap returns the underlying error
+func (rj *PaymentRejection) Unwrap() error {
+	return rj.Err
+}
+
+// WriteResponse writes the default response for the rejection, naming its reason in the
+// X-Payment-Error header
+func (rj *PaymentRejection) WriteResponse(w http.ResponseWriter) {
+	w.Header().Set(HeaderPaymentError, string(rj.Reason))
+	http.Error(w, rj.Message, rj.Status)
+}
+
+// rejectPayment responds to a rejected payment through OnPaymentRejected, if set, and
+// with the default response otherwise
+func rejectPayment(w http.ResponseWriter, r *http.Request, config *MiddlewareConfig, rejection *PaymentRejection) {
+	if config.OnPaymentRejected != nil {
+		config.OnPaymentRejected(w, r, rejection)
+		return
+	}
+	rejection.WriteResponse(w)
+}
+
+// nonceRejection returns the rejection of a payment whose nonce or quote was refused
+// with err, for which isRejection holds
+func nonceRejection(payment *Payment, err error) *PaymentRejection {
+	reason := RejectInvalidQuote
+	switch {
+	case errors.Is(err, ErrNonceUnknown):
+		reason = RejectUnknownNonce
+	case errors.Is(err, ErrNonceConsumed):
+		reason = RejectReplayed
+	case errors.Is(err, ErrNonceExpired):
+		reason = RejectExpired
+	}
+	return &PaymentRejection{
+		Reason:  reason,
+		Payment: payment,
+		Err:     err,
+		Status:  http.StatusPaymentRequired,
+		Message: "Payment rejected: " + err.Error(),
+	}
+}
+
+// verificationFailure classifies a payment that no quoted option accepted
+func verificationFailure(payment *Payment, options []*PaymentRequirements) RejectionReason {
+	if auth := payment.Authorization; auth != nil {
+		if validBefore, err := parse.Uint(auth.ValidBefore, "validBefore"); err == nil && validBefore.Cmp(big.NewInt(time.Now().Unix())) <= 0 {
+			return RejectExpired
+		}
+	}
+
+	paid, err := ParseAmount(payment.Amount)
+	matched, underpaid := false, true
+	for _, option := range options {
+		if !optionMatches(payment, option) {
+			continue
+		}
+		matched = true
+
+		required, requiredErr := ParseAmount(option.Amount)
+		if err != nil || requiredErr != nil || paid.Cmp(required) >= 0 {
+			underpaid = false
+		}
+	}
+
+	switch {
+	case !matched:
+		return RejectNoMatchingOption
+	case underpaid:
+		return RejectWrongAmount
+	default:
+		return RejectVerificationFailed
+	}
+}
@@ -0,0 +1,77 @@
+package x402go
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNonceRejection(t *testing.T) {
+	tests := []struct {
+		err  error
+		want RejectionReason
+	}{
+		{ErrNonceUnknown, RejectUnknownNonce},
+		{fmt.Errorf("lookup: %w", ErrNonceConsumed), RejectReplayed},
+		{ErrNonceExpired, RejectExpired},
+		{errors.New("bad quote signature"), RejectInvalidQuote},
+	}
+	for _, tt := range tests {
+		rejection := nonceRejection(&Payment{}, tt.err)
+		if rejection.Reason != tt.want {
+			t.Errorf("nonceRejection(%v) reason = %s, want %s", tt.err, rejection.Reason, tt.want)
+		}
+		if rejection.Status != http.StatusPaymentRequired || !errors.Is(rejection, tt.err) {
+			t.Errorf("nonceRejection(%v) = %+v", tt.err, rejection)
+		}
+
+		rec := httptest.NewRecorder()
+		rejection.WriteResponse(rec)
+		if rec.Code != http.StatusPaymentRequired || rec.Header().Get(HeaderPaymentError) != string(tt.want) {
+			t.Errorf("response %d with %s %q", rec.Code, HeaderPaymentError, rec.Header().Get(HeaderPaymentError))
+		}
+	}
+}
+
+func TestVerificationFailure(t *testing.T) {
+	requirements := testRequirements()
+	other := testRequirements()
+	other.Chain = "1"
+	other.Amount = "500"
+
+	future := strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10)
+	past := strconv.FormatInt(time.Now().Add(-time.Second).Unix(), 10)
+	payment := func(chain, amount, validBefore string) *Payment {
+		p := &Payment{Scheme: SchemeExact, Chain: chain, Token: requirements.Token, Amount: amount}
+		if validBefore != "" {
+			p.Authorization = &TransferAuthorization{Value: amount, ValidBefore: validBefore}
+		}
+		return p
+	}
+
+	tests := []struct {
+		name    string
+		payment *Payment
+		options []*PaymentRequirements
+		want    RejectionReason
+	}{
+		{"expired authorization", payment("8453", "1000", past), []*PaymentRequirements{requirements}, RejectExpired},
+		{"malformed validBefore", payment("8453", "1000", "soon"), []*PaymentRequirements{requirements}, RejectVerificationFailed},
+		{"no matching option", payment("10", "1000", future), []*PaymentRequirements{requirements, other}, RejectNoMatchingOption},
+		{"other token", &Payment{Scheme: SchemeExact, Chain: "8453", Token: "0x2222222222222222222222222222222222222222", Amount: "1000"}, []*PaymentRequirements{requirements}, RejectNoMatchingOption},
+		{"underpaid", payment("8453", "999", future), []*PaymentRequirements{requirements}, RejectWrongAmount},
+		{"covers a matching option", payment("1", "600", future), []*PaymentRequirements{requirements, other}, RejectVerificationFailed},
+		{"below every matching option", payment("1", "400", future), []*PaymentRequirements{requirements, other}, RejectWrongAmount},
+		{"unparsable amount", payment("8453", "lots", ""), []*PaymentRequirements{requirements}, RejectVerificationFailed},
+		{"paid in full", payment("8453", "1000", future), []*PaymentRequirements{requirements}, RejectVerificationFailed},
+	}
+	for _, tt := range tests {
+		if got := verificationFailure(tt.payment, tt.options); got != tt.want {
+			t.Errorf("%s: verificationFailure() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package x402go
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// RemoteSigner is a Signer that keeps the key in a remote signing service, reached over
+// JSON-RPC with eth_signTypedData_v4 and eth_signTransaction
+type RemoteSigner struct {
+	// URL is the JSON-RPC endpoint of the signing service
+	URL string
+
+	// Account is the account the service signs for
+	Account common.Address
+
+	// HTTPClient sends the requests (optional, defaults to http.DefaultClient)
+	HTTPClient *http.Client
+
+	id atomic.Uint64
+}
+
+// NewRemoteSigner creates a signer asking the service at url to sign for account
+func NewRemoteSigner(url string, account common.Address) *RemoteSigner {
+	return &RemoteSigner{URL: url, Account: account}
+}
+
+// Address implements Signer
+func (s *RemoteSigner) Address() common.Address {
+	return s.Account
+}
+
+// SignTypedData implements Signer
+func (s *RemoteSigner) SignTypedData(ctx context.Context, data apitypes.TypedData) ([]byte, error) {
+	var sig hexutil.Bytes
+	if err := s.call(ctx, "eth_signTypedData_v4", &sig, s.Account, data); err != nil {
+		return nil, err
+	}
+	if len(sig) != crypto.SignatureLength {
+		return nil, fmt.Errorf("remote signer returned a %d-byte signature", len(sig))
+	}
+
+	// Some signers return v as a recovery id
+	if sig[crypto.RecoveryIDOffset] < 27 {
+		sig[crypto.RecoveryIDOffset] += 27
+	}
+	return sig, nil
+}
+
+// SignTransaction implements Signer. The signed transaction the service returns must be
+// the requested one, signed by Account for chainID.
+func (s *RemoteSigner) SignTransaction(ctx context.Context, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	args := map[string]interface{}{
+		"from":    s.Account,
+		"to":      tx.To(),
+		"gas":     hexutil.Uint64(tx.Gas()),
+		"value":   (*hexutil.Big)(tx.Value()),
+		"data":    hexutil.Bytes(tx.Data()),
+		"nonce":   hexutil.Uint64(tx.Nonce()),
+		"chainId": (*hexutil.Big)(chainID),
+	}
+	if tx.Type() == types.LegacyTxType {
+		args["gasPrice"] = (*hexutil.Big)(tx.GasPrice())
+	} else {
+		args["maxFeePerGas"] = (*hexutil.Big)(tx.GasFeeCap())
+		args["maxPriorityFeePerGas"] = (*hexutil.Big)(tx.GasTipCap())
+	}
+
+	// Signers return the raw transaction, either bare or as the raw field of an object
+	var result json.RawMessage
+	if err := s.call(ctx, "eth_signTransaction", &result, args); err != nil {
+		return nil, err
+	}
+	var raw hexutil.Bytes
+	if err := json.Unmarshal(result, &raw); err != nil {
+		var signed struct {
+			Raw hexutil.Bytes `json:"raw"`
+		}
+		if err := json.Unmarshal(result, &signed); err != nil {
+			return nil, fmt.Errorf("failed to decode signed transaction: %w", err)
+		}
+		raw = signed.Raw
+	}
+
+	signed := new(types.Transaction)
+	if err := signed.UnmarshalBinary(raw); err != nil {
+		return nil, fmt.Errorf("failed to decode signed transaction: %w", err)
+	}
+	if err := s.checkSigned(tx, signed, chainID); err != nil {
+		return nil, fmt.Errorf("remote signer returned a different transaction: %w", err)
+	}
+	return signed, nil
+}
+
+// checkSigned compares a signed transaction with the one requested. Gas and fees may
+// not exceed those requested.
+func (s *RemoteSigner) checkSigned(tx, signed *types.Transaction, chainID *big.Int) error {
+	switch {
+	case signed.ChainId().Cmp(chainID) != 0:
+		return fmt.Errorf("chain ID %s, want %s", signed.ChainId(), chainID)
+	case signed.Nonce() != tx.Nonce():
+		return fmt.Errorf("nonce %d, want %d", signed.Nonce(), tx.Nonce())
+	case (signed.To() == nil) != (tx.To() == nil) || (tx.To() != nil && *signed.To() != *tx.To()):
+		return fmt.Errorf("recipient %v, want %v", signed.To(), tx.To())
+	case signed.Value().Cmp(tx.Value()) != 0:
+		return fmt.Errorf("value %s, want %s", signed.Value(), tx.Value())
+	case !bytes.Equal(signed.Data(), tx.Data()):
+		return fmt.Errorf("data differs")
+	case signed.Gas() > tx.Gas():
+		return fmt.Errorf("gas %d, want at most %d", signed.Gas(), tx.Gas())
+	case signed.GasFeeCap().Cmp(tx.GasFeeCap()) > 0 || signed.GasTipCap().Cmp(tx.GasTipCap()) > 0:
+		return fmt.Errorf("fees above those requested")
+	}
+
+	from, err := types.Sender(types.LatestSignerForChainID(chainID), signed)
+	if err != nil {
+		return err
+	}
+	if from != s.Account {
+		return fmt.Errorf("signed by %s, want %s", from.Hex(), s.Account.Hex())
+	}
+	return nil
+}
+
+// call invokes a JSON-RPC method on the signing service and decodes its result
+func (s *RemoteSigner) call(ctx context.Context, method string, result interface{}, params ...interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      s.id.Add(1),
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := s.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("remote signer request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("remote signer returned status %d", resp.StatusCode)
+	}
+
+	var reply struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return fmt.Errorf("failed to decode remote signer response: %w", err)
+	}
+	if reply.Error != nil {
+		return fmt.Errorf("remote signer refused %s: %s (code %d)", method, reply.Error.Message, reply.Error.Code)
+	}
+	return json.Unmarshal(reply.Result, result)
+}
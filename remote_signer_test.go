@@ -0,0 +1,133 @@
+package x402go
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// rpcServer serves JSON-RPC requests with result, called with each request's method
+func rpcServer(t *testing.T, result func(method string) interface{}) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("bad request: %v", err)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": result(req.Method)})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRemoteSignerSignTransaction(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	other, _ := crypto.GenerateKey()
+	account := crypto.PubkeyToAddress(key.PublicKey)
+	chainID := big.NewInt(8453)
+	to := common.HexToAddress("0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913")
+
+	request := &types.DynamicFeeTx{
+		ChainID:   chainID,
+		Nonce:     7,
+		GasTipCap: big.NewInt(1),
+		GasFeeCap: big.NewInt(100),
+		Gas:       60000,
+		To:        &to,
+		Data:      []byte{0xa9, 0x05, 0x9c, 0xbb},
+	}
+
+	elsewhere := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	tests := []struct {
+		name    string
+		key     *ecdsa.PrivateKey
+		edit    func(*types.DynamicFeeTx)
+		wantErr bool
+	}{
+		{"as requested", key, nil, false},
+		{"lower gas", key, func(tx *types.DynamicFeeTx) { tx.Gas = 50000 }, false},
+		{"other recipient", key, func(tx *types.DynamicFeeTx) { tx.To = &elsewhere }, true},
+		{"contract creation", key, func(tx *types.DynamicFeeTx) { tx.To = nil }, true},
+		{"other value", key, func(tx *types.DynamicFeeTx) { tx.Value = big.NewInt(1) }, true},
+		{"other data", key, func(tx *types.DynamicFeeTx) { tx.Data = []byte{0x01} }, true},
+		{"other nonce", key, func(tx *types.DynamicFeeTx) { tx.Nonce = 8 }, true},
+		{"other chain", key, func(tx *types.DynamicFeeTx) { tx.ChainID = big.NewInt(1) }, true},
+		{"more gas", key, func(tx *types.DynamicFeeTx) { tx.Gas = 70000 }, true},
+		{"higher fee", key, func(tx *types.DynamicFeeTx) { tx.GasFeeCap = big.NewInt(101) }, true},
+		{"other signer", other, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			returned := *request
+			if tt.edit != nil {
+				tt.edit(&returned)
+			}
+			signed, err := types.SignNewTx(tt.key, types.LatestSignerForChainID(returned.ChainID), &returned)
+			if err != nil {
+				t.Fatal(err)
+			}
+			raw, err := signed.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			server := rpcServer(t, func(method string) interface{} {
+				if method != "eth_signTransaction" {
+					t.Errorf("method = %s, want eth_signTransaction", method)
+				}
+				return map[string]interface{}{"raw": hexutil.Bytes(raw)}
+			})
+			got, err := NewRemoteSigner(server.URL, account).SignTransaction(context.Background(), types.NewTx(request), chainID)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && got.Hash() != signed.Hash() {
+				t.Errorf("returned transaction %s, want %s", got.Hash(), signed.Hash())
+			}
+		})
+	}
+}
+
+func TestRemoteSignerSignTypedData(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	account := crypto.PubkeyToAddress(key.PublicKey)
+	data := typedDataForTest(t)
+
+	want, err := NewPrivateKeySigner(key).SignTypedData(context.Background(), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Signers returning v as a recovery id or in the 27/28 form give the same signature
+	for _, v := range []byte{want[crypto.RecoveryIDOffset] - 27, want[crypto.RecoveryIDOffset]} {
+		sig := append([]byte(nil), want...)
+		sig[crypto.RecoveryIDOffset] = v
+		server := rpcServer(t, func(string) interface{} { return hexutil.Bytes(sig) })
+
+		got, err := NewRemoteSigner(server.URL, account).SignTypedData(context.Background(), data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hexutil.Encode(got) != hexutil.Encode(want) {
+			t.Errorf("v = %d: signature %x, want %x", v, got, want)
+		}
+	}
+
+	server := rpcServer(t, func(string) interface{} { return hexutil.Bytes(want[:64]) })
+	if _, err := NewRemoteSigner(server.URL, account).SignTypedData(context.Background(), data); err == nil {
+		t.Error("short signature accepted")
+	}
+}
@@ -0,0 +1,170 @@
+package x402go
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// initialRetryBackoff is how long the client first waits before resending a pending
+// payment when the server does not say, doubling on every further attempt
+const initialRetryBackoff = time.Second
+
+// maxRetryAfter caps the Retry-After delay the client honours
+const maxRetryAfter = time.Minute
+
+// maxRejectionBody is how much of a rejection's body the client reads
+const maxRejectionBody = 64 << 10
+
+// PaymentRejectedError is returned by Client when the server does not accept a payment
+type PaymentRejectedError struct {
+	// StatusCode is the status of the server's response
+	StatusCode int
+
+	// Reason is the rejection reason named by the server, empty if it named none
+	Reason RejectionReason
+
+	// Message is the server's explanation
+	Message string
+}
+
+// Error implements error
+func (e *PaymentRejectedError) Error() string {
+	msg := fmt.Sprintf("payment rejected with status %d", e.StatusCode)
+	if e.Reason != "" {
+		msg += " (" + string(e.Reason) + ")"
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// paidOutcome classifies the server's response to a paid request
+type paidOutcome int
+
+const (
+	// paidAccepted means the payment was accepted, or the response is not about it
+	paidAccepted paidOutcome = iota
+
+	// paidPending means the same payment should be resent after backing off
+	paidPending
+
+	// paidRequote means the quote was stale and a new payment should be made
+	paidRequote
+
+	// paidFatal means the payment will not be accepted
+	paidFatal
+)
+
+// classifyPaidResponse classifies the response to a paid request, returning the error to
+// report if the payment is not accepted in the end. The body of rejections is buffered so
+// it can still be read.
+func classifyPaidResponse(resp *http.Response) (paidOutcome, error) {
+	reason := RejectionReason(resp.Header.Get(HeaderPaymentError))
+	if resp.StatusCode != http.StatusPaymentRequired && reason == "" {
+		return paidAccepted, nil
+	}
+
+	// Pending payments are answered 402 with a Retry-After header
+	if resp.StatusCode == http.StatusPaymentRequired && reason == "" && resp.Header.Get("Retry-After") != "" {
+		return paidPending, fmt.Errorf("%w: retries exhausted", ErrPaymentPending)
+	}
+
+	rejection := &PaymentRejectedError{
+		StatusCode: resp.StatusCode,
+		Reason:     reason,
+		Message:    rejectionMessage(resp),
+	}
+	switch reason {
+	case RejectUnknownNonce, RejectInvalidQuote, RejectExpired, RejectReplayed, RejectWrongResource:
+		return paidRequote, rejection
+	case RejectFacilitatorError:
+		return paidPending, rejection
+	case "":
+		// Servers that do not name the reason re-quote by sending new requirements
+		if hasPaymentRequirements(resp) {
+			return paidRequote, rejection
+		}
+	}
+	return paidFatal, rejection
+}
+
+// rejectionMessage returns the explanation in a rejection's body: the error field of a
+// JSON body, or the text body itself
+func rejectionMessage(resp *http.Response) string {
+	body := bufferBody(resp)
+
+	var payload struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &payload) == nil && payload.Error != "" {
+		return payload.Error
+	}
+	return strings.TrimSpace(string(body))
+}
+
+// hasPaymentRequirements reports whether a 402 response carries payment options
+func hasPaymentRequirements(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusPaymentRequired {
+		return false
+	}
+	if resp.Header.Get(HeaderPayment) != "" {
+		return true
+	}
+
+	var body PaymentRequiredResponseV1
+	return json.Unmarshal(bufferBody(resp), &body) == nil && len(body.Accepts) > 0
+}
+
+// bufferBody reads up to maxRejectionBody of a response's body and replaces the body
+// with a buffered copy, so it can be read again
+func bufferBody(resp *http.Response) []byte {
+	if buffered, ok := resp.Body.(*bufferedBody); ok {
+		return buffered.data
+	}
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, maxRejectionBody))
+	resp.Body.Close()
+	resp.Body = &bufferedBody{Reader: bytes.NewReader(data), data: data}
+	return data
+}
+
+// bufferedBody is a response body read into memory
+type bufferedBody struct {
+	*bytes.Reader
+	data []byte
+}
+
+// Close implements io.Closer
+func (b *bufferedBody) Close() error {
+	return nil
+}
+
+// retryDelay returns how long to wait before resending a pending payment: the response's
+// Retry-After seconds if given, backoff otherwise
+func retryDelay(resp *http.Response, backoff time.Duration) time.Duration {
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+		return min(time.Duration(seconds)*time.Second, maxRetryAfter)
+	}
+	return backoff
+}
+
+// sleepContext waits for d, or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,88 @@
+package x402go
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+// paidReply is a server's answer to a paid request
+type paidReply struct {
+	status     int
+	reason     RejectionReason
+	retryAfter string
+}
+
+func TestClientRetries(t *testing.T) {
+	ok := paidReply{status: http.StatusOK}
+	rejected := func(reason RejectionReason) paidReply {
+		return paidReply{status: http.StatusPaymentRequired, reason: reason}
+	}
+	pending := paidReply{status: http.StatusPaymentRequired, retryAfter: "0"}
+
+	tests := []struct {
+		name        string
+		maxRetries  int
+		replies     []paidReply
+		wantSent    int
+		wantPaid    int
+		wantErr     bool
+		wantReason  RejectionReason
+		wantPending bool
+	}{
+		{"accepted", 1, []paidReply{ok}, 1, 1, false, "", false},
+		{"stale quote paid again", 1, []paidReply{rejected(RejectUnknownNonce), ok}, 2, 2, false, "", false},
+		{"replay paid again", 1, []paidReply{rejected(RejectReplayed), ok}, 2, 2, false, "", false},
+		{"expired paid again", 1, []paidReply{rejected(RejectExpired), ok}, 2, 2, false, "", false},
+		{"pending resent", 1, []paidReply{pending, ok}, 2, 1, false, "", false},
+		{"facilitator error resent", 1, []paidReply{{status: http.StatusBadGateway, reason: RejectFacilitatorError, retryAfter: "0"}, ok}, 2, 1, false, "", false},
+		{"wrong amount is terminal", 3, []paidReply{rejected(RejectWrongAmount), ok}, 1, 1, true, RejectWrongAmount, false},
+		{"verification failure is terminal", 3, []paidReply{rejected(RejectVerificationFailed), ok}, 1, 1, true, RejectVerificationFailed, false},
+		{"unnamed rejection is terminal", 3, []paidReply{{status: http.StatusPaymentRequired}, ok}, 1, 1, true, "", false},
+		{"no retries", 0, []paidReply{rejected(RejectInvalidQuote), ok}, 1, 1, true, RejectInvalidQuote, false},
+		{"retries exhausted", 2, []paidReply{rejected(RejectInvalidQuote), rejected(RejectInvalidQuote), rejected(RejectInvalidQuote), ok}, 3, 3, true, RejectInvalidQuote, false},
+		{"pending exhausted", 2, []paidReply{pending, pending, pending, ok}, 3, 1, true, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newPaidServer(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+				reply := tt.replies[attempt-1]
+				if reply.reason != "" {
+					w.Header().Set(HeaderPaymentError, string(reply.reason))
+				}
+				if reply.retryAfter != "" {
+					w.Header().Set("Retry-After", reply.retryAfter)
+				}
+				w.WriteHeader(reply.status)
+			})
+
+			handler, paid := countingHandler()
+			client := NewClientWithHandler(handler)
+			client.MaxRetries = tt.maxRetries
+
+			resp, err := client.Get(server.URL)
+			if err == nil {
+				resp.Body.Close()
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if len(server.payments) != tt.wantSent || *paid != tt.wantPaid {
+				t.Errorf("%d payments sent, %d made, want %d sent, %d made", len(server.payments), *paid, tt.wantSent, tt.wantPaid)
+			}
+
+			var rejection *PaymentRejectedError
+			switch {
+			case tt.wantPending:
+				if !errors.Is(err, ErrPaymentPending) {
+					t.Errorf("err = %v, want ErrPaymentPending", err)
+				}
+			case tt.wantErr:
+				if !errors.As(err, &rejection) || rejection.Reason != tt.wantReason {
+					t.Errorf("err = %v, want a rejection with reason %q", err, tt.wantReason)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package x402go
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrNoAcceptableOption is returned by a RequirementsSelector when none of the
+// server's payment options can be paid
+var ErrNoAcceptableOption = errors.New("no acceptable payment option")
+
+// RequirementsSelector chooses which of the payment options offered in a 402 response
+// the client pays. options is never empty.
+type RequirementsSelector func(options []*PaymentRequirements) (*PaymentRequirements, error)
+
+// SelectFirst pays the server's preferred option, the first one it lists
+func SelectFirst(options []*PaymentRequirements) (*PaymentRequirements, error) {
+	if len(options) == 0 {
+		return nil, ErrNoAcceptableOption
+	}
+	return options[0], nil
+}
+
+// SelectChains returns a selector that pays the first option on one of chains, given as
+// chain IDs or v1 network names. Chains are tried in the order given, so list the chain
+// where funds are preferably spent first.
+func SelectChains(chains ...string) RequirementsSelector {
+	return func(options []*PaymentRequirements) (*PaymentRequirements, error) {
+		for _, chain := range chains {
+			for _, option := range options {
+				if ChainForNetwork(option.Chain) == ChainForNetwork(chain) {
+					return option, nil
+				}
+			}
+		}
+		return nil, ErrNoAcceptableOption
+	}
+}
+
+// SelectTokens returns a selector that pays the first option in one of tokens, given as
+// token contract addresses. Tokens are tried in the order given.
+func SelectTokens(tokens ...string) RequirementsSelector {
+	return func(options []*PaymentRequirements) (*PaymentRequirements, error) {
+		for _, token := range tokens {
+			for _, option := range options {
+				if strings.EqualFold(option.Token, token) {
+					return option, nil
+				}
+			}
+		}
+		return nil, ErrNoAcceptableOption
+	}
+}
@@ -0,0 +1,81 @@
+package x402go
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSelectors(t *testing.T) {
+	option := func(chain, token string) *PaymentRequirements {
+		return &PaymentRequirements{Scheme: SchemeExact, Amount: "1000", Chain: chain, Token: token}
+	}
+	const (
+		usdcBase = "0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913"
+		usdcEth  = "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"
+	)
+	options := []*PaymentRequirements{option("1", usdcEth), option("8453", usdcBase), option("base-sepolia", usdcBase)}
+
+	tests := []struct {
+		name     string
+		selector RequirementsSelector
+		want     int
+	}{
+		{"first", SelectFirst, 0},
+		{"only chain", SelectChains("8453"), 1},
+		{"chain order wins over option order", SelectChains("84532", "1"), 2},
+		{"network names", SelectChains("base"), 1},
+		{"unknown chain skipped", SelectChains("10", "8453"), 1},
+		{"no chain", SelectChains("10"), -1},
+		{"token", SelectTokens(usdcBase), 1},
+		{"token ignores case", SelectTokens("0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48"), 0},
+		{"token order", SelectTokens("0x2222222222222222222222222222222222222222", usdcBase), 1},
+		{"no token", SelectTokens("0x2222222222222222222222222222222222222222"), -1},
+	}
+	for _, tt := range tests {
+		got, err := tt.selector(options)
+		if tt.want < 0 {
+			if !errors.Is(err, ErrNoAcceptableOption) {
+				t.Errorf("%s: selected %+v, %v, want ErrNoAcceptableOption", tt.name, got, err)
+			}
+			continue
+		}
+		if err != nil || got != options[tt.want] {
+			t.Errorf("%s: selected %+v, %v, want option %d", tt.name, got, err, tt.want)
+		}
+	}
+
+	if _, err := SelectFirst(nil); !errors.Is(err, ErrNoAcceptableOption) {
+		t.Errorf("SelectFirst(nil) = %v, want ErrNoAcceptableOption", err)
+	}
+}
+
+func TestAdvertisedOptions(t *testing.T) {
+	base := testRequirements()
+	eth := testRequirements()
+	eth.Chain = "1"
+	eth.Token = "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"
+	eth.Amount = "2000"
+
+	for _, format := range []WireFormat{WireFormatLegacy, WireFormatV1} {
+		handler := RequirePaymentWithConfig(&MiddlewareConfig{
+			Requirements: base,
+			Accepts:      []*PaymentRequirements{eth},
+			WireFormat:   format,
+		}, http.NotFoundHandler())
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "http://example.com/r", nil))
+
+		options, _, err := parsePaymentRequired(rec.Result())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(options) != 2 || options[0].Chain != "8453" || options[1].Chain != "1" || options[1].Amount != "2000" {
+			t.Fatalf("format %v: options %+v, want Requirements then Accepts", format, options)
+		}
+		if options[0].Nonce == "" || options[0].Nonce != options[1].Nonce {
+			t.Errorf("format %v: nonces %q and %q, want one shared nonce", format, options[0].Nonce, options[1].Nonce)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package x402go
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// SettlementMode selects when the middleware settles verified payments through the facilitator
+type SettlementMode int
+
+const (
+	// SettleNone leaves settlement to the application
+	SettleNone SettlementMode = iota
+
+	// SettleBeforeServe settles the payment before running the handler
+	SettleBeforeServe
+
+	// SettleAfterServe buffers the handler's response and settles only if the handler
+	// returned 2xx. The response is released only once settlement succeeds.
+	SettleAfterServe
+
+	// SettleAsync serves the response immediately and settles in the background if the
+	// handler returned 2xx. Failures are reported through OnSettlementError only. Payments
+	// buying an access pass are settled as with SettleAfterServe, since the pass is only
+	// granted once the payment has settled.
+	SettleAsync
+)
+
+// serveAndSettle runs the handler for a verified payment and settles it as configured.
+// "upto" payments are charged for their reported usage, so they are never settled before
+// serving, and payments buying a pass are never settled asynchronously. A pass bought by
+// the payment is granted once the payment has settled, or once it is verified when
+// settlement is off.
+func serveAndSettle(w http.ResponseWriter, r *http.Request, config *MiddlewareConfig, pc *PaymentContext, requirements *PaymentRequirements, next http.Handler) {
+	payment := &pc.Payment
+
+	mode := config.Settlement
+	if mode == SettleBeforeServe && requirements.Scheme == SchemeUpTo {
+		mode = SettleAfterServe
+	}
+	if mode == SettleAsync && requirements.Pass != nil && len(config.PassKey) > 0 {
+		mode = SettleAfterServe
+	}
+
+	switch mode {
+	case SettleBeforeServe:
+		resp, err := settlePayment(r.Context(), config, payment, requirements, "")
+		if err != nil {
+			settlementFailed(w, config, payment, resp, err)
+			return
+		}
+		pc.Settlement = resp
+		writeSettlementHeader(w, config, payment, resp)
+		grantPass(w, r, config, pc)
+		next.ServeHTTP(w, r)
+
+	case SettleAfterServe:
+		buf := newBufferedResponseWriter()
+		next.ServeHTTP(buf, r)
+
+		// Only successful responses are charged for
+		if !isSuccessStatus(buf.statusCode()) {
+			buf.flushTo(w)
+			return
+		}
+
+		resp, err := settlePayment(r.Context(), config, payment, requirements, pc.chargedAmount())
+		if err != nil {
+			settlementFailed(w, config, payment, resp, err)
+			return
+		}
+		pc.Settlement = resp
+		writeSettlementHeader(w, config, payment, resp)
+		grantPass(w, r, config, pc)
+		buf.flushTo(w)
+
+	case SettleAsync:
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		if !isSuccessStatus(rec.statusCode()) {
+			return
+		}
+
+		// The request's context ends with the response, so settle detached from its cancellation
+		ctx := context.WithoutCancel(r.Context())
+		amount := pc.chargedAmount()
+		go func() {
+			if _, err := settlePayment(ctx, config, payment, requirements, amount); err != nil && config.OnSettlementError != nil {
+				config.OnSettlementError(payment, err)
+			}
+		}()
+
+	default:
+		grantPass(w, r, config, pc)
+		next.ServeHTTP(w, r)
+	}
+}
+
+// settlePayment settles a payment through the configured facilitator, charging amount if
+// set and the full authorized value otherwise. The facilitator's response is returned
+// alongside the error when it refused to settle.
+func settlePayment(ctx context.Context, config *MiddlewareConfig, payment *Payment, requirements *PaymentRequirements, amount string) (*SettleResponse, error) {
+	resp, err := AsContextFacilitator(config.Facilitator).SettleContext(ctx, &SettleRequest{
+		Payment:      *payment,
+		Amount:       amount,
+		Requirements: requirements,
+	})
+	if err != nil {
+		return nil, &FacilitatorError{Err: err}
+	}
+	if !resp.Settled {
+		if resp.Error != "" {
+			return resp, fmt.Errorf("payment not settled: %s", resp.Error)
+		}
+		return resp, fmt.Errorf("payment not settled")
+	}
+	return resp, nil
+}
+
+// settlementFailed reports a failed settlement and responds 402 without the content
+func settlementFailed(w http.ResponseWriter, config *MiddlewareConfig, payment *Payment, resp *SettleResponse, err error) {
+	if config.OnSettlementError != nil {
+		config.OnSettlementError(payment, err)
+	}
+	if resp != nil {
+		writeSettlementHeader(w, config, payment, resp)
+	}
+	http.Error(w, "Payment settlement failed", http.StatusPaymentRequired)
+}
+
+// writeSettlementHeader adds the settlement result to the response in the configured wire format
+func writeSettlementHeader(w http.ResponseWriter, config *MiddlewareConfig, payment *Payment, resp *SettleResponse) {
+	var header string
+	var err error
+	if config.WireFormat == WireFormatLegacy {
+		var data []byte
+		data, err = json.Marshal(resp)
+		header = string(data)
+	} else {
+		header, err = EncodeHeaderV1(resp.ToV1(payment))
+	}
+	if err == nil {
+		w.Header().Set(HeaderPaymentResponse, header)
+	}
+}
+
+// isSuccessStatus reports whether status is 2xx
+func isSuccessStatus(status int) bool {
+	return status >= 200 && status < 300
+}
+
+// bufferedResponseWriter holds a handler's response so it can be released or discarded later
+type bufferedResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newBufferedResponseWriter() *bufferedResponseWriter {
+	return &bufferedResponseWriter{
+		header: make(http.Header),
+	}
+}
+
+// Header implements http.ResponseWriter
+func (b *bufferedResponseWriter) Header() http.Header {
+	return b.header
+}
+
+// Write implements http.ResponseWriter
+func (b *bufferedResponseWriter) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(p)
+}
+
+// WriteHeader implements http.ResponseWriter
+func (b *bufferedResponseWriter) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+// statusCode returns the status the handler wrote, defaulting to 200
+func (b *bufferedResponseWriter) statusCode() int {
+	if b.status == 0 {
+		return http.StatusOK
+	}
+	return b.status
+}
+
+// flushTo writes the buffered response to w
+func (b *bufferedResponseWriter) flushTo(w http.ResponseWriter) {
+	for key, values := range b.header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(b.statusCode())
+	w.Write(b.body.Bytes())
+}
+
+// statusRecorder passes a response through while recording its status
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader implements http.ResponseWriter
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter
+func (s *statusRecorder) Write(p []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(p)
+}
+
+// Unwrap returns the underlying ResponseWriter for http.ResponseController
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// statusCode returns the status the handler wrote, defaulting to 200
+func (s *statusRecorder) statusCode() int {
+	if s.status == 0 {
+		return http.StatusOK
+	}
+	return s.status
+}
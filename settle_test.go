@@ -0,0 +1,58 @@
+package x402go
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// stubFacilitator settles every payment with a fixed response
+type stubFacilitator struct {
+	settle *SettleResponse
+}
+
+func (f *stubFacilitator) Verify(req *VerifyRequest) (*VerifyResponse, error) {
+	return &VerifyResponse{Valid: true}, nil
+}
+
+func (f *stubFacilitator) Settle(req *SettleRequest) (*SettleResponse, error) {
+	return f.settle, nil
+}
+
+func TestSettlePassGrantedAfterSettlement(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     SettlementMode
+		settled  bool
+		wantPass bool
+	}{
+		{"async settled", SettleAsync, true, true},
+		{"async refused", SettleAsync, false, false},
+		{"after serve settled", SettleAfterServe, true, true},
+		{"after serve refused", SettleAfterServe, false, false},
+		{"before serve refused", SettleBeforeServe, false, false},
+		{"no settlement", SettleNone, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &MiddlewareConfig{
+				Settlement:  tt.mode,
+				Facilitator: &stubFacilitator{settle: &SettleResponse{Settled: tt.settled, TxHash: "0x01"}},
+				PassKey:     []byte("0123456789abcdef"),
+			}
+			requirements := testRequirements()
+			requirements.Pass = &PassTerms{Duration: 3600}
+			pc := &PaymentContext{Payment: Payment{Sender: "0xabc"}, Verified: true, Requirements: requirements}
+
+			rec := httptest.NewRecorder()
+			serveAndSettle(rec, httptest.NewRequest("GET", "/data", nil), config, pc, requirements, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("ok"))
+			}))
+
+			if hasPass := rec.Header().Get(HeaderPaymentPass) != ""; hasPass != tt.wantPass {
+				t.Fatalf("pass granted %v, want %v (status %d)", hasPass, tt.wantPass, rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package x402go
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+
+	"github.com/berhberhberh/x402go/internal/parse"
+)
+
+// erc20TransferSelector is the selector of the ERC-20 transfer(address,uint256) function
+var erc20TransferSelector = crypto.Keccak256([]byte("transfer(address,uint256)"))[:4]
+
+// Signer holds a payer's account and signs on its behalf
+type Signer interface {
+	// Address returns the account the signer signs for
+	Address() common.Address
+
+	// SignTypedData signs EIP-712 typed data and returns the 65-byte signature, with v in
+	// the 27/28 form
+	SignTypedData(ctx context.Context, data apitypes.TypedData) ([]byte, error)
+
+	// SignTransaction signs a transaction for chainID
+	SignTransaction(ctx context.Context, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error)
+}
+
+// PrivateKeySigner is a Signer holding a private key in memory
+type PrivateKeySigner struct {
+	key *ecdsa.PrivateKey
+}
+
+// NewPrivateKeySigner creates a signer for key
+func NewPrivateKeySigner(key *ecdsa.PrivateKey) *PrivateKeySigner {
+	return &PrivateKeySigner{key: key}
+}
+
+// NewKeystoreSigner decrypts a go-ethereum encrypted keystore file with passphrase and
+// creates a signer for its key
+func NewKeystoreSigner(path, passphrase string) (*PrivateKeySigner, error) {
+	keyJSON, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keystore file: %w", err)
+	}
+
+	key, err := keystore.DecryptKey(keyJSON, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt keystore file: %w", err)
+	}
+	return NewPrivateKeySigner(key.PrivateKey), nil
+}
+
+// Address implements Signer
+func (s *PrivateKeySigner) Address() common.Address {
+	return crypto.PubkeyToAddress(s.key.PublicKey)
+}
+
+// SignTypedData implements Signer
+func (s *PrivateKeySigner) SignTypedData(ctx context.Context, data apitypes.TypedData) ([]byte, error) {
+	hash, _, err := apitypes.TypedDataAndHash(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash typed data: %w", err)
+	}
+
+	sig, err := crypto.Sign(hash, s.key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign typed data: %w", err)
+	}
+	sig[crypto.RecoveryIDOffset] += 27
+	return sig, nil
+}
+
+// SignTransaction implements Signer
+func (s *PrivateKeySigner) SignTransaction(ctx context.Context, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return types.SignTx(tx, types.LatestSignerForChainID(chainID), s.key)
+}
+
+// TransferBackend is the chain access needed to pay with ERC-20 transfer transactions:
+// the payer's next nonce, gas price and limit, and submission of the signed transaction.
+type TransferBackend interface {
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+	SuggestGasPrice(ctx context.Context) (*big.Int, error)
+	EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error)
+	SendTransaction(ctx context.Context, tx *types.Transaction) error
+}
+
+// NewSignerPaymentHandler returns a context-aware payment handler that pays with signer.
+// "exact" and "upto" requirements naming the token's EIP-712 domain are paid with a signed
+// transferWithAuthorization. Other "exact" requirements are paid by submitting an ERC-20
+// transfer through backend and passing its TxHash; backend may be nil to sign authorizations only.
+func NewSignerPaymentHandler(signer Signer, backend TransferBackend) func(context.Context, *PaymentRequirements) (*Payment, error) {
+	return func(ctx context.Context, requirements *PaymentRequirements) (*Payment, error) {
+		if requirements.Scheme != SchemeExact && requirements.Scheme != SchemeUpTo {
+			return nil, fmt.Errorf("unsupported payment scheme %q", requirements.Scheme)
+		}
+
+		domain, err := DomainForRequirements(requirements)
+		if err == nil {
+			return payWithAuthorization(ctx, signer, domain, requirements)
+		}
+		if backend == nil || requirements.Scheme != SchemeExact {
+			return nil, err
+		}
+		return payWithTransfer(ctx, signer, backend, requirements)
+	}
+}
+
+// payWithAuthorization pays requirements with a transferWithAuthorization signed under domain
+func payWithAuthorization(ctx context.Context, signer Signer, domain *EIP712Domain, requirements *PaymentRequirements) (*Payment, error) {
+	auth, err := NewTransferAuthorization(signer.Address(), requirements)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := signer.SignTypedData(ctx, auth.TypedData(domain))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign authorization: %w", err)
+	}
+
+	return &Payment{
+		Scheme:        requirements.Scheme,
+		Chain:         requirements.Chain,
+		Token:         requirements.Token,
+		Amount:        auth.Value,
+		Sender:        auth.From,
+		Recipient:     auth.To,
+		Nonce:         auth.Nonce,
+		Timestamp:     time.Now().Unix(),
+		Signature:     hexutil.Encode(sig),
+		Authorization: auth,
+	}, nil
+}
+
+// payWithTransfer pays requirements by submitting an ERC-20 transfer to the recipient
+func payWithTransfer(ctx context.Context, signer Signer, backend TransferBackend, requirements *PaymentRequirements) (*Payment, error) {
+	chainID, err := ChainID(requirements.Chain)
+	if err != nil {
+		return nil, err
+	}
+	token, err := parse.Address(requirements.Token)
+	if err != nil {
+		return nil, err
+	}
+	recipient, err := parse.Address(requirements.Recipient)
+	if err != nil {
+		return nil, err
+	}
+	amount, err := ParseAmount(requirements.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	data := append([]byte(nil), erc20TransferSelector...)
+	data = append(data, common.LeftPadBytes(recipient.Bytes(), 32)...)
+	data = append(data, common.LeftPadBytes(amount.BigInt().Bytes(), 32)...)
+
+	from := signer.Address()
+	nonce, err := backend.PendingNonceAt(ctx, from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read account nonce: %w", err)
+	}
+	gasPrice, err := backend.SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to suggest gas price: %w", err)
+	}
+	gas, err := backend.EstimateGas(ctx, ethereum.CallMsg{From: from, To: &token, Data: data})
+	if err != nil {
+		return nil, fmt.Errorf("failed to estimate gas: %w", err)
+	}
+
+	tx, err := signer.SignTransaction(ctx, types.NewTx(&types.LegacyTx{
+		Nonce:    nonce,
+		GasPrice: gasPrice,
+		Gas:      gas,
+		To:       &token,
+		Data:     data,
+	}), chainID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign transfer: %w", err)
+	}
+	if err := backend.SendTransaction(ctx, tx); err != nil {
+		return nil, fmt.Errorf("failed to send transfer: %w", err)
+	}
+
+	return &Payment{
+		Scheme:    requirements.Scheme,
+		TxHash:    tx.Hash().Hex(),
+		Chain:     requirements.Chain,
+		Token:     requirements.Token,
+		Amount:    requirements.Amount,
+		Sender:    from.Hex(),
+		Recipient: requirements.Recipient,
+		Nonce:     requirements.Nonce,
+		Timestamp: time.Now().Unix(),
+	}, nil
+}
@@ -0,0 +1,99 @@
+package x402go
+
+import (
+	"context"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// typedDataForTest returns the typed data of an authorization for testRequirements
+func typedDataForTest(t *testing.T) apitypes.TypedData {
+	requirements := testRequirements()
+	requirements.Nonce = "0x0a00000000000000000000000000000000000000000000000000000000000000"
+	domain, err := DomainForRequirements(requirements)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth, err := NewTransferAuthorization(common.HexToAddress("0x3333333333333333333333333333333333333333"), requirements)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return auth.TypedData(domain)
+}
+
+// checkSigner checks that signer's signatures recover to its address
+func checkSigner(t *testing.T, signer Signer) {
+	data := typedDataForTest(t)
+	sig, err := signer.SignTypedData(context.Background(), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := sig[crypto.RecoveryIDOffset]; v != 27 && v != 28 {
+		t.Errorf("v = %d, want 27 or 28", v)
+	}
+	hash, _, _ := apitypes.TypedDataAndHash(data)
+	sig[crypto.RecoveryIDOffset] -= 27
+	pub, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crypto.PubkeyToAddress(*pub) != signer.Address() {
+		t.Errorf("typed data signed by %s, want %s", crypto.PubkeyToAddress(*pub), signer.Address())
+	}
+
+	chainID := big.NewInt(8453)
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	tx, err := signer.SignTransaction(context.Background(), types.NewTx(&types.DynamicFeeTx{
+		ChainID: chainID, Nonce: 1, GasFeeCap: big.NewInt(1), Gas: 21000, To: &to,
+	}), chainID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	from, err := types.Sender(types.LatestSignerForChainID(chainID), tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != signer.Address() {
+		t.Errorf("transaction signed by %s, want %s", from, signer.Address())
+	}
+}
+
+func TestPrivateKeySigner(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := NewPrivateKeySigner(key)
+	if signer.Address() != crypto.PubkeyToAddress(key.PublicKey) {
+		t.Fatalf("Address() = %s, want the key's address", signer.Address())
+	}
+	checkSigner(t, signer)
+}
+
+func TestKeystoreSigner(t *testing.T) {
+	account, err := keystore.StoreKey(t.TempDir(), "secret", keystore.LightScryptN, keystore.LightScryptP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := account.URL.Path
+
+	signer, err := NewKeystoreSigner(path, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signer.Address() != account.Address {
+		t.Fatalf("Address() = %s, want %s", signer.Address(), account.Address)
+	}
+	checkSigner(t, signer)
+
+	if _, err := NewKeystoreSigner(path, "wrong"); err == nil {
+		t.Error("wrong passphrase accepted")
+	}
+	if _, err := NewKeystoreSigner(filepath.Join(t.TempDir(), "missing.json"), "secret"); err == nil {
+		t.Error("missing keystore file accepted")
+	}
+}
@@ -0,0 +1,242 @@
+package x402go
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrSpendDenied is returned when a SpendPolicy refuses a payment
+var ErrSpendDenied = errors.New("spend denied by policy")
+
+// Spend is a payment the client is about to make
+type Spend struct {
+	// Host is the host of the request being paid for
+	Host string
+
+	// Requirements is the payment option about to be paid. "upto" options are counted at
+	// their maximum amount.
+	Requirements *PaymentRequirements
+
+	// ID identifies the spend once a policy has recorded it
+	ID string
+}
+
+// SpendPolicy decides whether the client may make a payment. Client consults it before
+// running the payment handler.
+type SpendPolicy interface {
+	// Authorize allows a payment, recording it against any budgets, or fails with an
+	// error wrapping ErrSpendDenied
+	Authorize(ctx context.Context, spend *Spend) error
+
+	// Release returns an authorized spend to the budgets when the payment was not made
+	Release(ctx context.Context, spend *Spend) error
+}
+
+// TokenLimit caps what a BudgetPolicy lets the client spend in one token. Zero amounts
+// leave the corresponding cap unset.
+type TokenLimit struct {
+	// Chain and Token identify the token, as chain ID or v1 network name and contract address
+	Chain string
+	Token string
+
+	// MaxPerRequest caps a single payment
+	MaxPerRequest Amount
+
+	// Hourly and Daily cap the total spent over the last hour and the last 24 hours
+	Hourly Amount
+	Daily  Amount
+
+	// ApprovalAbove sends payments above this amount to BudgetPolicy.Approve
+	ApprovalAbove Amount
+}
+
+// BudgetPolicy is a SpendPolicy enforcing per-token limits, rolling budgets and
+// allowlists. Empty allowlists allow everything; denylists take precedence.
+type BudgetPolicy struct {
+	// Store records spending so budgets hold across restarts (optional, defaults to an
+	// in-memory store)
+	Store SpendStore
+
+	// Limits lists the tokens the client may pay with. When set, payments in other tokens
+	// are denied.
+	Limits []TokenLimit
+
+	// AllowedRecipients and DeniedRecipients filter the addresses paid. Escrows holding
+	// "upto" payments are checked too, and must be allowed explicitly.
+	AllowedRecipients []string
+	DeniedRecipients  []string
+
+	// AllowedHosts and DeniedHosts filter the hosts paid for, by host name without port
+	AllowedHosts []string
+	DeniedHosts  []string
+
+	// AllowedChains lists the chains payments may be made on, as chain IDs or v1 network names
+	AllowedChains []string
+
+	// Approve asks a human to approve payments above a limit's ApprovalAbove. Without it,
+	// such payments are denied.
+	Approve func(ctx context.Context, spend *Spend) (bool, error)
+
+	mu sync.Mutex
+}
+
+// Authorize implements SpendPolicy
+func (p *BudgetPolicy) Authorize(ctx context.Context, spend *Spend) error {
+	requirements := spend.Requirements
+	amount, err := ParseAmount(requirements.Amount)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSpendDenied, err)
+	}
+
+	escrow := authorizationRecipient(requirements)
+	host := spend.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	switch {
+	case containsFold(p.DeniedHosts, host) || (len(p.AllowedHosts) > 0 && !containsFold(p.AllowedHosts, host)):
+		return fmt.Errorf("%w: host %s is not allowed", ErrSpendDenied, host)
+	case !p.recipientAllowed(requirements.Recipient, false):
+		return fmt.Errorf("%w: recipient %s is not allowed", ErrSpendDenied, requirements.Recipient)
+	case !strings.EqualFold(escrow, requirements.Recipient) && !p.recipientAllowed(escrow, true):
+		return fmt.Errorf("%w: escrow %s is not allowed", ErrSpendDenied, escrow)
+	case len(p.AllowedChains) > 0 && !containsChain(p.AllowedChains, requirements.Chain):
+		return fmt.Errorf("%w: chain %s is not allowed", ErrSpendDenied, requirements.Chain)
+	}
+
+	limit, ok := p.limit(requirements)
+	if !ok {
+		if len(p.Limits) > 0 {
+			return fmt.Errorf("%w: token %s on chain %s is not allowed", ErrSpendDenied, requirements.Token, requirements.Chain)
+		}
+		limit = &TokenLimit{}
+	}
+	if !limit.MaxPerRequest.IsZero() && amount.Cmp(limit.MaxPerRequest) > 0 {
+		return fmt.Errorf("%w: amount %s exceeds the per-request maximum %s", ErrSpendDenied, amount, limit.MaxPerRequest)
+	}
+
+	// Approval may wait on a human, so it runs before the budgets are locked
+	if !limit.ApprovalAbove.IsZero() && amount.Cmp(limit.ApprovalAbove) > 0 {
+		if p.Approve == nil {
+			return fmt.Errorf("%w: amount %s needs approval", ErrSpendDenied, amount)
+		}
+		approved, err := p.Approve(ctx, spend)
+		if err != nil {
+			return fmt.Errorf("approval failed: %w", err)
+		}
+		if !approved {
+			return fmt.Errorf("%w: amount %s was not approved", ErrSpendDenied, amount)
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	store := p.store()
+
+	now := time.Now()
+	if err := store.Expire(now.Add(-24 * time.Hour)); err != nil {
+		return err
+	}
+
+	account := spendAccount(requirements.Chain, requirements.Token)
+	budgets := []struct {
+		name   string
+		limit  Amount
+		period time.Duration
+	}{
+		{"hourly", limit.Hourly, time.Hour},
+		{"daily", limit.Daily, 24 * time.Hour},
+	}
+	for _, budget := range budgets {
+		if budget.limit.IsZero() {
+			continue
+		}
+		spent, err := store.Total(account, now.Add(-budget.period))
+		if err != nil {
+			return err
+		}
+		if amount.Add(spent).Cmp(budget.limit) > 0 {
+			return fmt.Errorf("%w: amount %s exceeds what remains of the %s budget", ErrSpendDenied, amount, budget.name)
+		}
+	}
+
+	spend.ID = generateNonce()
+	return store.Add(&SpendRecord{
+		ID:      spend.ID,
+		Account: account,
+		Amount:  amount,
+		Time:    now,
+	})
+}
+
+// Release implements SpendPolicy
+func (p *BudgetPolicy) Release(ctx context.Context, spend *Spend) error {
+	if spend.ID == "" {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.store().Remove(spend.ID)
+}
+
+// store returns the spend store, creating the default one on first use
+func (p *BudgetPolicy) store() SpendStore {
+	if p.Store == nil {
+		p.Store = NewMemorySpendStore()
+	}
+	return p.Store
+}
+
+// recipientAllowed reports whether the allowlists let payments go to address. An escrow
+// receives the whole authorized amount, so an empty allowlist does not allow it.
+func (p *BudgetPolicy) recipientAllowed(address string, escrow bool) bool {
+	if containsFold(p.DeniedRecipients, address) {
+		return false
+	}
+	if len(p.AllowedRecipients) == 0 {
+		return !escrow
+	}
+	return containsFold(p.AllowedRecipients, address)
+}
+
+// limit returns the limit of the token paid with requirements
+func (p *BudgetPolicy) limit(requirements *PaymentRequirements) (*TokenLimit, bool) {
+	for i := range p.Limits {
+		limit := &p.Limits[i]
+		if ChainForNetwork(limit.Chain) == ChainForNetwork(requirements.Chain) && strings.EqualFold(limit.Token, requirements.Token) {
+			return limit, true
+		}
+	}
+	return nil, false
+}
+
+// spendAccount returns the key spending in a token is recorded under
+func spendAccount(chain, token string) string {
+	return ChainForNetwork(chain) + "/" + strings.ToLower(token)
+}
+
+// containsFold reports whether list contains s, ignoring case
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsChain reports whether chains contains chain, in any network form
+func containsChain(chains []string, chain string) bool {
+	for _, c := range chains {
+		if ChainForNetwork(c) == ChainForNetwork(chain) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,191 @@
+package x402go
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SpendRecord is a payment recorded against a client's budgets
+type SpendRecord struct {
+	ID string `json:"id"`
+
+	// Account identifies the token the payment was made in
+	Account string `json:"account"`
+
+	// Amount is what was paid, in base units of the token
+	Amount Amount `json:"amount"`
+
+	// Time is when the payment was authorized
+	Time time.Time `json:"time"`
+}
+
+// SpendStore keeps the payments a client made, for rolling budgets
+type SpendStore interface {
+	// Add records a payment
+	Add(record *SpendRecord) error
+
+	// Remove drops a payment that was not made after all
+	Remove(id string) error
+
+	// Total returns the amount paid from an account since a point in time
+	Total(account string, since time.Time) (Amount, error)
+
+	// Expire drops payments made before a point in time
+	Expire(before time.Time) error
+}
+
+// spendSet is the shared bookkeeping behind the SpendStore implementations
+type spendSet map[string]*SpendRecord
+
+func (s spendSet) add(record *SpendRecord) error {
+	if record.ID == "" {
+		return fmt.Errorf("spend id cannot be empty")
+	}
+	if _, exists := s[record.ID]; exists {
+		return fmt.Errorf("spend %q already recorded", record.ID)
+	}
+	copied := *record
+	s[record.ID] = &copied
+	return nil
+}
+
+func (s spendSet) total(account string, since time.Time) Amount {
+	var total Amount
+	for _, record := range s {
+		if record.Account == account && !record.Time.Before(since) {
+			total = total.Add(record.Amount)
+		}
+	}
+	return total
+}
+
+// expire removes records made before a point in time and returns them
+func (s spendSet) expire(before time.Time) spendSet {
+	var removed spendSet
+	for id, record := range s {
+		if record.Time.Before(before) {
+			if removed == nil {
+				removed = make(spendSet)
+			}
+			removed[id] = record
+			delete(s, id)
+		}
+	}
+	return removed
+}
+
+// MemorySpendStore is an in-memory SpendStore. Budgets reset when the process restarts.
+type MemorySpendStore struct {
+	mu     sync.Mutex
+	spends spendSet
+}
+
+// NewMemorySpendStore creates an empty in-memory spend store
+func NewMemorySpendStore() *MemorySpendStore {
+	return &MemorySpendStore{
+		spends: make(spendSet),
+	}
+}
+
+// Add implements SpendStore
+func (s *MemorySpendStore) Add(record *SpendRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.spends.add(record)
+}
+
+// Remove implements SpendStore
+func (s *MemorySpendStore) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.spends, id)
+	return nil
+}
+
+// Total implements SpendStore
+func (s *MemorySpendStore) Total(account string, since time.Time) (Amount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.spends.total(account, since), nil
+}
+
+// Expire implements SpendStore
+func (s *MemorySpendStore) Expire(before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.spends.expire(before)
+	return nil
+}
+
+// FileSpendStore is a SpendStore persisted as a JSON file, so budgets hold across
+// process restarts. A change that cannot be saved is not applied.
+type FileSpendStore struct {
+	mu     sync.Mutex
+	file   jsonFile
+	spends spendSet
+}
+
+// NewFileSpendStore opens the spend store at path. The file is created on the first
+// change if it does not exist.
+func NewFileSpendStore(path string) (*FileSpendStore, error) {
+	s := &FileSpendStore{
+		file:   jsonFile{path: path, name: "spend store"},
+		spends: make(spendSet),
+	}
+	if err := s.file.load(&s.spends); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Add implements SpendStore
+func (s *FileSpendStore) Add(record *SpendRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.spends.add(record); err != nil {
+		return err
+	}
+	return s.file.commit(s.spends, func() {
+		delete(s.spends, record.ID)
+	})
+}
+
+// Remove implements SpendStore
+func (s *FileSpendStore) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.spends[id]
+	if !ok {
+		return nil
+	}
+	delete(s.spends, id)
+	return s.file.commit(s.spends, func() {
+		s.spends[id] = record
+	})
+}
+
+// Total implements SpendStore
+func (s *FileSpendStore) Total(account string, since time.Time) (Amount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.spends.total(account, since), nil
+}
+
+// Expire implements SpendStore
+func (s *FileSpendStore) Expire(before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := s.spends.expire(before)
+	if len(removed) == 0 {
+		return nil
+	}
+	return s.file.commit(s.spends, func() {
+		for id, record := range removed {
+			s.spends[id] = record
+		}
+	})
+}
@@ -0,0 +1,152 @@
+package x402go
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBudgetPolicyRecipients(t *testing.T) {
+	const (
+		recipient = "0x1111111111111111111111111111111111111111"
+		escrow    = "0x2222222222222222222222222222222222222222"
+		other     = "0x000000000000000000000000000000000000dEaD"
+	)
+
+	exact := func(to string) *PaymentRequirements {
+		return &PaymentRequirements{Scheme: SchemeExact, Amount: "1000", Chain: "8453", Recipient: to}
+	}
+	upto := func(to, via string) *PaymentRequirements {
+		requirements := &PaymentRequirements{Scheme: SchemeUpTo, Amount: "1000", Chain: "8453", Recipient: to}
+		if via != "" {
+			requirements.Extra = map[string]interface{}{escrowExtraKey: via}
+		}
+		return requirements
+	}
+
+	tests := []struct {
+		name         string
+		allowed      []string
+		denied       []string
+		requirements *PaymentRequirements
+		wantDenied   bool
+	}{
+		{"no lists", nil, nil, exact(recipient), false},
+		{"allowed recipient", []string{recipient}, nil, exact(recipient), false},
+		{"allowlist ignores case", []string{"0xAbCd000000000000000000000000000000000000"}, nil, exact("0xabcd000000000000000000000000000000000000"), false},
+		{"recipient not allowlisted", []string{recipient}, nil, exact(other), true},
+		{"denied recipient", nil, []string{recipient}, exact(recipient), true},
+		{"denylist wins", []string{recipient}, []string{recipient}, exact(recipient), true},
+		{"upto without escrow", []string{recipient}, nil, upto(recipient, ""), false},
+		{"allowed escrow", []string{recipient, escrow}, nil, upto(recipient, escrow), false},
+		{"escrow not allowlisted", []string{recipient}, nil, upto(recipient, other), true},
+		{"escrow with empty allowlist", nil, nil, upto(recipient, escrow), true},
+		{"denied escrow", []string{recipient, escrow}, []string{escrow}, upto(recipient, escrow), true},
+		{"escrow ignored for exact", []string{recipient}, nil, &PaymentRequirements{Scheme: SchemeExact, Amount: "1000", Chain: "8453", Recipient: recipient, Extra: map[string]interface{}{escrowExtraKey: other}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := &BudgetPolicy{AllowedRecipients: tt.allowed, DeniedRecipients: tt.denied}
+			err := policy.Authorize(context.Background(), &Spend{Host: "api.example.com", Requirements: tt.requirements})
+			if denied := errors.Is(err, ErrSpendDenied); denied != tt.wantDenied {
+				t.Fatalf("Authorize() = %v, want denied %v", err, tt.wantDenied)
+			}
+		})
+	}
+}
+
+func TestBudgetPolicyLimits(t *testing.T) {
+	const token = "0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913"
+
+	tests := []struct {
+		name       string
+		limit      TokenLimit
+		amounts    []string
+		wantDenied []bool
+	}{
+		{"per request", TokenLimit{MaxPerRequest: mustAmount(t, "100")}, []string{"100", "101"}, []bool{false, true}},
+		{"hourly", TokenLimit{Hourly: mustAmount(t, "250")}, []string{"100", "100", "100"}, []bool{false, false, true}},
+		{"daily", TokenLimit{Daily: mustAmount(t, "150")}, []string{"100", "50", "1"}, []bool{false, false, true}},
+		{"approval without approver", TokenLimit{ApprovalAbove: mustAmount(t, "10")}, []string{"10", "11"}, []bool{false, true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.limit.Chain, tt.limit.Token = "8453", token
+			policy := &BudgetPolicy{Limits: []TokenLimit{tt.limit}}
+			for i, amount := range tt.amounts {
+				spend := &Spend{Requirements: &PaymentRequirements{Scheme: SchemeExact, Amount: amount, Chain: "8453", Token: token}}
+				err := policy.Authorize(context.Background(), spend)
+				if denied := errors.Is(err, ErrSpendDenied); denied != tt.wantDenied[i] {
+					t.Fatalf("payment %d of %s: Authorize() = %v, want denied %v", i, amount, err, tt.wantDenied[i])
+				}
+			}
+		})
+	}
+}
+
+func mustAmount(t *testing.T, s string) Amount {
+	t.Helper()
+	amount, err := ParseAmount(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return amount
+}
+
+func TestFileSpendStore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "store")
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "spends.json")
+	store, err := NewFileSpendStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	for _, record := range []*SpendRecord{
+		{ID: "old", Account: "a", Amount: mustAmount(t, "5"), Time: now.Add(-48 * time.Hour)},
+		{ID: "new", Account: "a", Amount: mustAmount(t, "7"), Time: now},
+	} {
+		if err := store.Add(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reopened, err := NewFileSpendStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total, _ := reopened.Total("a", now.Add(-72*time.Hour)); total.String() != "12" {
+		t.Fatalf("total after reopening %s, want 12", total)
+	}
+
+	// Changes that cannot be saved are not applied
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	steps := []struct {
+		name   string
+		change func() error
+	}{
+		{"add", func() error {
+			return store.Add(&SpendRecord{ID: "more", Account: "a", Amount: mustAmount(t, "100"), Time: now})
+		}},
+		{"remove", func() error { return store.Remove("new") }},
+		{"expire", func() error { return store.Expire(now.Add(-24 * time.Hour)) }},
+	}
+	for _, step := range steps {
+		if err := step.change(); err == nil {
+			t.Fatalf("%s succeeded without saving", step.name)
+		}
+		if total, _ := store.Total("a", now.Add(-72*time.Hour)); total.String() != "12" {
+			t.Fatalf("total after failed %s %s, want 12", step.name, total)
+		}
+	}
+}
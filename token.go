@@ -0,0 +1,77 @@
+package x402go
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// ErrInvalidToken is returned when a signed token is malformed or its signature does not match
+var ErrInvalidToken = errors.New("invalid token signature")
+
+// minTokenKeyLength is the minimum HMAC key length accepted for signing tokens
+const minTokenKeyLength = 16
+
+// Token kinds bind a signature to the purpose of its token, so a token of one kind is
+// never accepted as another even when the same key signs both
+const (
+	tokenQuote   = "quote"
+	tokenSession = "session"
+	tokenPass    = "pass"
+)
+
+// signToken encodes v as JSON and signs it with HMAC-SHA256 under key for the given token
+// kind. The token is the base64url payload and signature joined by a dot.
+func signToken(key []byte, kind string, v interface{}) (string, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(tokenMAC(key, kind, encoded)), nil
+}
+
+// verifyToken checks the signature of a token of the given kind made by signToken and
+// decodes its payload into v
+func verifyToken(key []byte, kind, token string, v interface{}) error {
+	encoded, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return ErrInvalidToken
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, tokenMAC(key, kind, encoded)) {
+		return ErrInvalidToken
+	}
+	return decodeToken(token, v)
+}
+
+// decodeToken decodes the payload of a token made by signToken into v without checking
+// its signature. Clients use it to read tokens they cannot verify.
+func decodeToken(token string, v interface{}) error {
+	encoded, _, ok := strings.Cut(token, ".")
+	if !ok {
+		return ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	if err := json.Unmarshal(payload, v); err != nil {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// tokenMAC computes the HMAC-SHA256 of an encoded token payload, prefixed by its kind
+func tokenMAC(key []byte, kind, encoded string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(kind + "."))
+	h.Write([]byte(encoded))
+	return h.Sum(nil)
+}
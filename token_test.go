@@ -0,0 +1,61 @@
+package x402go
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyToken(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	otherKey := []byte("fedcba9876543210")
+
+	sign := func(key []byte, kind string, v interface{}) string {
+		token, err := signToken(key, kind, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	pass := sign(key, tokenPass, &Pass{Scope: "/", Sender: "0xabc", Expiry: time.Now().Add(time.Hour).Unix()})
+	session := sign(key, tokenSession, &Session{Sender: "0xabc", Expiry: time.Now().Add(time.Hour).Unix()})
+	quote := sign(key, tokenQuote, &Quote{Resource: "/r", Expiry: time.Now().Add(time.Hour)})
+
+	payload, sig, _ := strings.Cut(pass, ".")
+	tampered := payload[:len(payload)-1] + "A." + sig
+
+	tests := []struct {
+		name    string
+		key     []byte
+		kind    string
+		token   string
+		wantErr bool
+	}{
+		{"pass", key, tokenPass, pass, false},
+		{"session", key, tokenSession, session, false},
+		{"quote", key, tokenQuote, quote, false},
+		{"pass as session", key, tokenSession, pass, true},
+		{"pass as quote", key, tokenQuote, pass, true},
+		{"session as pass", key, tokenPass, session, true},
+		{"quote as session", key, tokenSession, quote, true},
+		{"other key", otherKey, tokenPass, pass, true},
+		{"tampered payload", key, tokenPass, tampered, true},
+		{"no signature", key, tokenPass, payload, true},
+		{"empty", key, tokenPass, "", true},
+		{"garbage signature", key, tokenPass, payload + ".!!", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v map[string]interface{}
+			err := verifyToken(tt.key, tt.kind, tt.token, &v)
+			if tt.wantErr && !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("verifyToken() = %v, want %v", err, ErrInvalidToken)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("verifyToken() = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package x402go
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/berhberhberh/x402go/registry"
+)
+
+// TokenInfo describes a payment token on a chain
+type TokenInfo struct {
+	// Chain is the chain ID the token is deployed on
+	Chain string
+
+	// Address is the token contract address
+	Address string
+
+	// Symbol is the token's ticker symbol (e.g. "USDC")
+	Symbol string
+
+	// Decimals is the number of decimals of the token's base unit
+	Decimals int
+}
+
+// TokenRegistry maps (chain, token address) pairs to token details. Chains may be given
+// as chain IDs, v1 network names or CAIP-2 ids and addresses are matched case-insensitively.
+type TokenRegistry struct {
+	mu     sync.RWMutex
+	tokens map[string]TokenInfo
+
+	// known makes Lookup fall back to the tokens of the registry package
+	known bool
+}
+
+// DefaultTokens is the registry of well-known tokens. It looks tokens up in the registry
+// package at call time, so tokens registered there later are known too. Tokens registered
+// with DefaultTokens.Register take precedence.
+var DefaultTokens = &TokenRegistry{tokens: make(map[string]TokenInfo), known: true}
+
+// NewTokenRegistry creates a registry holding tokens
+func NewTokenRegistry(tokens ...TokenInfo) *TokenRegistry {
+	r := &TokenRegistry{tokens: make(map[string]TokenInfo)}
+	for _, token := range tokens {
+		r.Register(token)
+	}
+	return r
+}
+
+// Register adds or replaces a token
+func (r *TokenRegistry) Register(token TokenInfo) {
+	token.Chain = ChainForNetwork(token.Chain)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens[tokenKey(token.Chain, token.Address)] = token
+}
+
+// Lookup returns the token at address on chain
+func (r *TokenRegistry) Lookup(chain, address string) (TokenInfo, bool) {
+	r.mu.RLock()
+	token, ok := r.tokens[tokenKey(chain, address)]
+	r.mu.RUnlock()
+	if ok || !r.known {
+		return token, ok
+	}
+
+	known, ok := registry.LookupToken(chain, address)
+	if !ok {
+		return TokenInfo{}, false
+	}
+	return TokenInfo{
+		Chain:    known.ChainID(),
+		Address:  known.Address(),
+		Symbol:   known.Symbol,
+		Decimals: known.Decimals,
+	}, true
+}
+
+// ParseAmount parses an amount written in whole tokens, e.g. "1.50", for the token at
+// address on chain
+func (r *TokenRegistry) ParseAmount(chain, address, s string) (Amount, error) {
+	token, ok := r.Lookup(chain, address)
+	if !ok {
+		return Amount{}, fmt.Errorf("unknown token %s on chain %s", address, chain)
+	}
+	return ParseDecimalAmount(s, token.Decimals)
+}
+
+// FormatAmount formats an amount of the token at address on chain for display, e.g.
+// "1.5 USDC". Amounts of unknown tokens are formatted in base units.
+func (r *TokenRegistry) FormatAmount(chain, address string, amount Amount) string {
+	token, ok := r.Lookup(chain, address)
+	if !ok {
+		return amount.String()
+	}
+	return amount.Format(token.Decimals) + " " + token.Symbol
+}
+
+// tokenKey is the registry key of a token
+func tokenKey(chain, address string) string {
+	return ChainForNetwork(chain) + "/" + strings.ToLower(address)
+}
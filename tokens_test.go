@@ -0,0 +1,60 @@
+package x402go
+
+import (
+	"testing"
+
+	"github.com/berhberhberh/x402go/registry"
+)
+
+func TestDefaultTokens(t *testing.T) {
+	amount, _ := ParseAmount("1500000")
+	tests := []struct {
+		chain   string
+		address string
+		want    string
+	}{
+		{"8453", "0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913", "1.5 USDC"},
+		{"base", "0x833589fcd6edb6e08f4c7c32d4f71b54bda02913", "1.5 USDC"},
+		{"eip155:8453", "0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913", "1.5 USDC"},
+		{"1", "0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913", "1500000"},
+		{"8453", "0x2222222222222222222222222222222222222222", "1500000"},
+	}
+	for _, tt := range tests {
+		if got := DefaultTokens.FormatAmount(tt.chain, tt.address, amount); got != tt.want {
+			t.Errorf("FormatAmount(%s, %s) = %q, want %q", tt.chain, tt.address, got, tt.want)
+		}
+	}
+
+	// Tokens registered with the registry package after init are known
+	address := "0x3333333333333333333333333333333333333333"
+	if _, ok := DefaultTokens.Lookup("8453", address); ok {
+		t.Fatal("unregistered token known")
+	}
+	registry.RegisterToken(registry.Token{Asset: registry.ERC20("8453", address), Symbol: "TST", Decimals: 2})
+	if got := DefaultTokens.FormatAmount("base", address, amount); got != "15000 TST" {
+		t.Errorf("FormatAmount() of a token registered later = %q, want %q", got, "15000 TST")
+	}
+
+	// Tokens registered with DefaultTokens take precedence
+	DefaultTokens.Register(TokenInfo{Chain: "8453", Address: address, Symbol: "OVR", Decimals: 6})
+	if got := DefaultTokens.FormatAmount("8453", address, amount); got != "1.5 OVR" {
+		t.Errorf("FormatAmount() of an overridden token = %q, want %q", got, "1.5 OVR")
+	}
+}
+
+func TestTokenRegistry(t *testing.T) {
+	r := NewTokenRegistry(TokenInfo{Chain: "base", Address: "0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913", Symbol: "USDC", Decimals: 6})
+
+	amount, err := r.ParseAmount("eip155:8453", "0x833589FCD6EDB6E08F4C7C32D4F71B54BDA02913", "2.5")
+	if err != nil || amount.String() != "2500000" {
+		t.Fatalf("ParseAmount() = %s, %v, want 2500000", amount, err)
+	}
+	if _, err := r.ParseAmount("8453", "0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913", "2.0000001"); err == nil {
+		t.Error("ParseAmount() accepted more decimals than the token has")
+	}
+
+	// A registry of its own does not know the registry package's tokens
+	if _, err := r.ParseAmount("1", "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48", "1"); err == nil {
+		t.Error("ParseAmount() of an unregistered token succeeded")
+	}
+}
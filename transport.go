@@ -0,0 +1,37 @@
+package x402go
+
+import (
+	"net/http"
+)
+
+// Transport is an http.RoundTripper that pays for requests answered with 402, so any
+// http.Client, including those inside third-party SDKs, makes x402 payments. Payments are
+// made with the configuration of Client, whose sessions and passes it shares. Requests
+// with a body are replayed through GetBody, which http.NewRequest sets for in-memory bodies.
+type Transport struct {
+	// Client configures payments: the payment handler, option selection and spending policy
+	Client *Client
+
+	// Base sends the requests (optional, defaults to http.DefaultTransport)
+	Base http.RoundTripper
+}
+
+// NewTransport creates a transport paying with client's configuration and sending
+// requests through base
+func NewTransport(client *Client, base http.RoundTripper) *Transport {
+	return &Transport{Client: client, Base: base}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return t.Client.do(req, base.RoundTrip)
+}
+
+// HTTPClient returns an http.Client that pays for requests with the client's configuration
+func (c *Client) HTTPClient() *http.Client {
+	return &http.Client{Transport: NewTransport(c, c.httpClient.Transport)}
+}
@@ -0,0 +1,156 @@
+package x402go
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// paidServer answers requests without payment with a v1 402 quoting testRequirements,
+// and paid requests with paid, called with the number of paid requests so far. It
+// records the bodies of all requests.
+type paidServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	bodies   []string
+	payments []string
+}
+
+func newPaidServer(t *testing.T, paid func(w http.ResponseWriter, r *http.Request, attempt int)) *paidServer {
+	s := &paidServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		s.bodies = append(s.bodies, string(body))
+		header := r.Header.Get(HeaderPayment)
+		if header != "" {
+			s.payments = append(s.payments, header)
+		}
+		attempt := len(s.payments)
+		s.mu.Unlock()
+
+		if header == "" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusPaymentRequired)
+			json.NewEncoder(w).Encode(PaymentRequiredResponseV1{
+				X402Version: X402Version,
+				Accepts:     []PaymentRequirementsV1{testRequirements().ToV1()},
+			})
+			return
+		}
+		paid(w, r, attempt)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// countingHandler returns a payment handler paying requirements with a stub payment,
+// and the number of payments it made
+func countingHandler() (func(*PaymentRequirements) (*Payment, error), *int) {
+	calls := 0
+	return func(requirements *PaymentRequirements) (*Payment, error) {
+		calls++
+		return &Payment{
+			Scheme:    requirements.Scheme,
+			Chain:     requirements.Chain,
+			Token:     requirements.Token,
+			Amount:    requirements.Amount,
+			Recipient: requirements.Recipient,
+			Sender:    "0x3333333333333333333333333333333333333333",
+			Signature: "0x01",
+		}, nil
+	}, &calls
+}
+
+func TestTransportPays(t *testing.T) {
+	server := newPaidServer(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+		w.Write([]byte("paid"))
+	})
+	handler, calls := countingHandler()
+	client := &http.Client{Transport: NewTransport(NewClientWithHandler(handler), nil)}
+
+	resp, err := client.Post(server.URL, "text/plain", strings.NewReader("request body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || string(body) != "paid" {
+		t.Fatalf("response %d %q, want 200 paid", resp.StatusCode, body)
+	}
+	if *calls != 1 || len(server.payments) != 1 {
+		t.Errorf("%d payments made, %d sent, want 1", *calls, len(server.payments))
+	}
+	if len(server.bodies) != 2 || server.bodies[0] != "request body" || server.bodies[1] != "request body" {
+		t.Errorf("bodies sent %q, want the request body twice", server.bodies)
+	}
+}
+
+func TestTransportUnreplayableBody(t *testing.T) {
+	server := newPaidServer(t, func(w http.ResponseWriter, r *http.Request, attempt int) {})
+	handler, _ := countingHandler()
+	client := &http.Client{Transport: NewTransport(NewClientWithHandler(handler), nil)}
+
+	// A body http.NewRequest cannot snapshot has no GetBody
+	req, _ := http.NewRequest("POST", server.URL, io.MultiReader(bytes.NewReader([]byte("request body"))))
+	if _, err := client.Do(req); err == nil {
+		t.Fatal("request with an unreplayable body was paid for")
+	}
+	if len(server.payments) != 0 {
+		t.Errorf("%d payments sent, want none", len(server.payments))
+	}
+}
+
+func TestTransportSpendPolicy(t *testing.T) {
+	server := newPaidServer(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+		t.Error("refused payment was sent")
+	})
+	handler, calls := countingHandler()
+	x402 := NewClientWithHandler(handler)
+	x402.SpendPolicy = &BudgetPolicy{DeniedRecipients: []string{testRequirements().Recipient}}
+	client := &http.Client{Transport: NewTransport(x402, nil)}
+
+	_, err := client.Get(server.URL)
+	if !errors.Is(err, ErrSpendDenied) {
+		t.Fatalf("err = %v, want ErrSpendDenied", err)
+	}
+	if *calls != 0 {
+		t.Errorf("payment handler called %d times, want 0", *calls)
+	}
+}
+
+func TestTransportPassesThrough(t *testing.T) {
+	var seen http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.Header.Clone()
+		w.Header().Set("X-Custom", "kept")
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("untouched"))
+	}))
+	defer server.Close()
+
+	handler, calls := countingHandler()
+	client := &http.Client{Transport: NewTransport(NewClientWithHandler(handler), nil)}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusTeapot || resp.Header.Get("X-Custom") != "kept" || string(body) != "untouched" {
+		t.Errorf("response %d %q %q, want it untouched", resp.StatusCode, resp.Header.Get("X-Custom"), body)
+	}
+	if *calls != 0 || seen.Get(HeaderPayment) != "" {
+		t.Errorf("payment made for a response other than 402")
+	}
+}
@@ -0,0 +1,182 @@
+package x402go
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// TxStore records which payment nonce each on-chain transaction paid, so a transaction
+// pays for a single quote
+type TxStore interface {
+	// Claim binds a transaction to a payment nonce until expiry, after which the
+	// transaction must no longer be accepted as payment. It reports false if the
+	// transaction is bound to another nonce. Claiming it again for the same nonce
+	// succeeds, so a payment whose nonce could not be redeemed can be retried; the nonce
+	// store then keeps the payment from being replayed.
+	Claim(txHash, nonce string, expiry time.Time) (bool, error)
+}
+
+// txBinding is the nonce a transaction paid and when the binding can be dropped
+type txBinding struct {
+	Nonce  string    `json:"nonce"`
+	Expiry time.Time `json:"expiry"`
+}
+
+// txSet is the shared bookkeeping behind the TxStore implementations, mapping
+// transaction hashes to the nonces they paid
+type txSet map[string]*txBinding
+
+// claim binds txHash to nonce, reporting whether it is bound to nonce and whether the
+// binding is new. An expired binding is replaced.
+func (s txSet) claim(txHash, nonce string, expiry, now time.Time) (bound, added bool, err error) {
+	if nonce == "" {
+		return false, false, fmt.Errorf("payment nonce cannot be empty")
+	}
+	if binding, ok := s[txHash]; ok && !now.After(binding.Expiry) {
+		return binding.Nonce == nonce, false, nil
+	}
+	s[txHash] = &txBinding{Nonce: nonce, Expiry: expiry}
+	return true, true, nil
+}
+
+// expire removes expired bindings and reports whether anything was removed
+func (s txSet) expire(now time.Time) bool {
+	removed := false
+	for txHash, binding := range s {
+		if now.After(binding.Expiry) {
+			delete(s, txHash)
+			removed = true
+		}
+	}
+	return removed
+}
+
+// MemoryTxStore is an in-memory TxStore. Expired bindings are swept as transactions are
+// claimed, at most every SweepInterval.
+type MemoryTxStore struct {
+	mu        sync.Mutex
+	txs       txSet
+	lastSweep time.Time
+
+	// SweepInterval is the minimum time between sweeps of expired bindings (default: 1 minute)
+	SweepInterval time.Duration
+}
+
+// NewMemoryTxStore creates an empty in-memory transaction store
+func NewMemoryTxStore() *MemoryTxStore {
+	return &MemoryTxStore{
+		txs:           make(txSet),
+		lastSweep:     time.Now(),
+		SweepInterval: time.Minute,
+	}
+}
+
+// Claim implements TxStore
+func (s *MemoryTxStore) Claim(txHash, nonce string, expiry time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastSweep) >= s.SweepInterval {
+		s.txs.expire(now)
+		s.lastSweep = now
+	}
+
+	bound, _, err := s.txs.claim(txHash, nonce, expiry, now)
+	return bound, err
+}
+
+// FileTxStore is a TxStore persisted as an append-only log, so transactions that paid
+// before a restart cannot pay again. Each claim appends a record and syncs it to disk
+// before the payment is accepted; a claim that cannot be saved is not applied. The log is
+// rewritten without expired bindings each time it doubles in size.
+type FileTxStore struct {
+	mu  sync.Mutex
+	log appendLog
+	txs txSet
+}
+
+// txRecord is a record of the FileTxStore log
+type txRecord struct {
+	Tx string `json:"tx"`
+	txBinding
+}
+
+// NewFileTxStore opens the transaction store at path, creating the file if it does not exist
+func NewFileTxStore(path string) (*FileTxStore, error) {
+	s := &FileTxStore{
+		log: appendLog{path: path, name: "transaction store"},
+		txs: make(txSet),
+	}
+	if err := s.log.replay(s.apply); err != nil {
+		return nil, err
+	}
+
+	// Start from a compacted log, which also drops a record torn by a crash
+	s.txs.expire(time.Now())
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// apply applies a record of the log
+func (s *FileTxStore) apply(data []byte) error {
+	var record txRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return err
+	}
+	binding := record.txBinding
+	s.txs[record.Tx] = &binding
+	return nil
+}
+
+// Claim implements TxStore
+func (s *FileTxStore) Claim(txHash, nonce string, expiry time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.txs[txHash]
+	bound, added, err := s.txs.claim(txHash, nonce, expiry, time.Now())
+	if err != nil || !added {
+		return bound, err
+	}
+	if err := s.log.append(&txRecord{Tx: txHash, txBinding: *s.txs[txHash]}, true); err != nil {
+		if previous != nil {
+			s.txs[txHash] = previous
+		} else {
+			delete(s.txs, txHash)
+		}
+		s.compact()
+		return false, err
+	}
+	return true, s.compactIfSparse()
+}
+
+// Close closes the log file
+func (s *FileTxStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.log.close()
+}
+
+// compactIfSparse compacts the log once it has doubled in size, dropping expired bindings
+func (s *FileTxStore) compactIfSparse() error {
+	if !s.log.sparse() {
+		return nil
+	}
+	s.txs.expire(time.Now())
+	return s.compact()
+}
+
+// compact rewrites the log with one record per binding held. After a failed append it
+// repairs the log; the append's error is what gets reported.
+func (s *FileTxStore) compact() error {
+	records := make([]interface{}, 0, len(s.txs))
+	for txHash, binding := range s.txs {
+		records = append(records, &txRecord{Tx: txHash, txBinding: *binding})
+	}
+	return s.log.compact(records)
+}
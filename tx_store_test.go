@@ -0,0 +1,116 @@
+package x402go
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTxStores(t *testing.T) {
+	fileStore, err := NewFileTxStore(filepath.Join(t.TempDir(), "txs.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fileStore.Close()
+	stores := map[string]TxStore{
+		"memory": NewMemoryTxStore(),
+		"file":   fileStore,
+	}
+
+	live := time.Now().Add(time.Hour)
+	expired := time.Now().Add(-time.Second)
+	steps := []struct {
+		name    string
+		tx      string
+		nonce   string
+		expiry  time.Time
+		want    bool
+		wantErr bool
+	}{
+		{"first claim", "0x01", "a", live, true, false},
+		{"same nonce", "0x01", "a", live, true, false},
+		{"other nonce", "0x01", "b", live, false, false},
+		{"other transaction", "0x02", "b", live, true, false},
+		{"no nonce", "0x03", "", live, false, true},
+		{"expired claim", "0x04", "c", expired, true, false},
+		{"after expiry", "0x04", "d", live, true, false},
+	}
+
+	for name, store := range stores {
+		for _, step := range steps {
+			claimed, err := store.Claim(step.tx, step.nonce, step.expiry)
+			if (err != nil) != step.wantErr {
+				t.Fatalf("%s %s: err = %v, want error %v", name, step.name, err, step.wantErr)
+			}
+			if claimed != step.want {
+				t.Fatalf("%s %s: claimed = %v, want %v", name, step.name, claimed, step.want)
+			}
+		}
+	}
+}
+
+func TestFileTxStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "txs.json")
+	expiry := time.Now().Add(time.Hour)
+	store, err := NewFileTxStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claimed, err := store.Claim("0x01", "a", expiry); err != nil || !claimed {
+		t.Fatalf("Claim() = %v, %v", claimed, err)
+	}
+	store.Close()
+
+	// A record torn by a crash while it was appended is dropped
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte(`{"tx":"0x02","no`))
+	f.Close()
+
+	// Claims survive a restart
+	reopened, err := NewFileTxStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if claimed, _ := reopened.Claim("0x01", "b", expiry); claimed {
+		t.Fatal("reopened store let a transaction pay for another nonce")
+	}
+
+	// Claims that cannot be saved are not applied
+	reopened.log.file.Close()
+	if _, err := reopened.Claim("0x02", "b", expiry); err == nil {
+		t.Fatal("Claim() succeeded without saving")
+	}
+	if claimed, err := reopened.Claim("0x02", "c", expiry); err != nil || !claimed {
+		t.Fatalf("Claim() after a failed save = %v, %v", claimed, err)
+	}
+}
+
+func TestFileTxStoreCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "txs.json")
+	store, err := NewFileTxStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	// Expired bindings are dropped from the log as it grows
+	for i := 0; i < 3*minLogCompaction; i++ {
+		if _, err := store.Claim(fmt.Sprintf("0x%x", i), "a", time.Now().Add(-time.Millisecond)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records := bytes.Count(data, []byte("\n")); records > minLogCompaction {
+		t.Fatalf("log holds %d records, want at most %d", records, minLogCompaction)
+	}
+}
@@ -8,28 +8,43 @@ import (
 // PaymentRequirements defines the payment details required by a server
 type PaymentRequirements struct {
 	// Scheme defines the payment scheme (e.g., "exact")
-	Scheme string `json:"scheme"`
+	Scheme string `json:"scheme" yaml:"scheme"`
 
 	// Amount is the payment amount in the smallest unit of the token
-	Amount string `json:"amount"`
+	Amount string `json:"amount" yaml:"amount"`
 
 	// Token is the contract address of the payment token
-	Token string `json:"token"`
+	Token string `json:"token" yaml:"token"`
 
 	// Chain is the blockchain network ID
-	Chain string `json:"chain"`
+	Chain string `json:"chain" yaml:"chain"`
 
 	// Recipient is the address that should receive the payment
-	Recipient string `json:"recipient"`
+	Recipient string `json:"recipient" yaml:"recipient"`
 
 	// Nonce is a unique identifier for this payment request
-	Nonce string `json:"nonce,omitempty"`
+	Nonce string `json:"nonce,omitempty" yaml:"nonce,omitempty"`
 
 	// Expiry is when this payment requirement expires (Unix timestamp)
-	Expiry int64 `json:"expiry,omitempty"`
+	Expiry int64 `json:"expiry,omitempty" yaml:"expiry,omitempty"`
 
 	// Facilitator is the URL of the facilitator server (optional)
-	Facilitator string `json:"facilitator,omitempty"`
+	Facilitator string `json:"facilitator,omitempty" yaml:"facilitator,omitempty"`
+
+	// Resource is the URL of the resource being paid for (optional, filled in by the middleware)
+	Resource string `json:"resource,omitempty" yaml:"resource,omitempty"`
+
+	// Description describes the resource to the payer (optional)
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+
+	// MimeType is the content type of the resource (optional)
+	MimeType string `json:"mimeType,omitempty" yaml:"mimeType,omitempty"`
+
+	// Pass sells an access pass rather than a single request (optional)
+	Pass *PassTerms `json:"pass,omitempty" yaml:"pass,omitempty"`
+
+	// Extra holds scheme-specific details such as the token's EIP-712 name and version (optional)
+	Extra map[string]interface{} `json:"extra,omitempty" yaml:"extra,omitempty"`
 }
 
 // ToJSON converts PaymentRequirements to JSON string
@@ -69,6 +84,15 @@ type Payment struct {
 
 	// Timestamp is when the payment was made
 	Timestamp int64 `json:"timestamp,omitempty"`
+
+	// Signature is the payer's signature over Authorization (EIP-3009 payments only)
+	Signature string `json:"signature,omitempty"`
+
+	// Authorization is a signed transferWithAuthorization used instead of TxHash (EIP-3009 payments only)
+	Authorization *TransferAuthorization `json:"authorization,omitempty"`
+
+	// Quote is the server's signed quote the payment was made against, echoed back from the requirements
+	Quote string `json:"quote,omitempty"`
 }
 
 // ToJSON converts Payment to JSON string
@@ -89,6 +113,12 @@ func (p *Payment) FromJSON(jsonStr string) error {
 type VerifyRequest struct {
 	TxHash string `json:"txHash"`
 	Chain  string `json:"chain"`
+
+	// Payment is the full payment being verified (optional)
+	Payment *Payment `json:"payment,omitempty"`
+
+	// Requirements are the requirements the payment must satisfy (optional)
+	Requirements *PaymentRequirements `json:"requirements,omitempty"`
 }
 
 // VerifyResponse represents a response from payment verification
@@ -106,6 +136,13 @@ type VerifyResponse struct {
 // SettleRequest represents a request to settle a payment
 type SettleRequest struct {
 	Payment Payment `json:"payment"`
+
+	// Amount is the amount to charge, at most the authorized value ("upto" payments only,
+	// defaults to the full value)
+	Amount string `json:"amount,omitempty"`
+
+	// Requirements are the requirements the payment was made against (optional)
+	Requirements *PaymentRequirements `json:"requirements,omitempty"`
 }
 
 // SettleResponse represents a response from payment settlement
@@ -118,7 +155,25 @@ type SettleResponse struct {
 
 // PaymentContext holds information about a verified payment
 type PaymentContext struct {
-	Payment   Payment
-	Verified  bool
+	Payment    Payment
+	Verified   bool
 	VerifiedAt time.Time
+
+	// Requirements is the payment option the payment was made against
+	Requirements *PaymentRequirements
+
+	// Settlement is the settlement result, set when the payment was settled before serving
+	Settlement *SettleResponse
+
+	// Usage is the amount consumed by an "upto" payment, as reported with ReportUsage
+	Usage *Amount
+
+	// Session is the prepaid balance the request was served from, in deposit mode
+	Session *Session
+
+	// Balance is what remains of the prepaid balance after this request, in deposit mode
+	Balance *Amount
+
+	// Pass is the access pass the request was served under, instead of a payment
+	Pass *Pass
 }
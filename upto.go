@@ -0,0 +1,51 @@
+package x402go
+
+import (
+	"fmt"
+)
+
+// escrowExtraKey is the requirements Extra key naming the address an "upto" authorization
+// pays, when the facilitator holds the funds in escrow to charge part of them
+const escrowExtraKey = "escrow"
+
+// ReportUsage records the amount actually consumed by an "upto" payment. Settlement charges
+// this amount instead of the authorized maximum. It fails for other schemes and for amounts
+// above the maximum. Handlers that never report usage are charged the maximum.
+func (pc *PaymentContext) ReportUsage(amount Amount) error {
+	if pc.Requirements == nil || pc.Requirements.Scheme != SchemeUpTo {
+		return fmt.Errorf("usage can only be reported for %q payments", SchemeUpTo)
+	}
+
+	max, err := ParseAmount(pc.Requirements.Amount)
+	if err != nil {
+		return err
+	}
+	if amount.Cmp(max) > 0 {
+		return fmt.Errorf("usage %s exceeds the authorized maximum %s", amount, max)
+	}
+
+	pc.Usage = &amount
+	return nil
+}
+
+// chargedAmount returns the amount to settle for the payment, empty for the full value
+func (pc *PaymentContext) chargedAmount() string {
+	if pc.Requirements == nil || pc.Requirements.Scheme != SchemeUpTo {
+		return ""
+	}
+	if pc.Usage == nil {
+		return pc.Requirements.Amount
+	}
+	return pc.Usage.String()
+}
+
+// authorizationRecipient returns the address a transfer authorization for requirements
+// must pay: the escrow of an "upto" payment if one is named, the recipient otherwise
+func authorizationRecipient(requirements *PaymentRequirements) string {
+	if requirements.Scheme == SchemeUpTo {
+		if escrow, ok := requirements.Extra[escrowExtraKey].(string); ok && escrow != "" {
+			return escrow
+		}
+	}
+	return requirements.Recipient
+}
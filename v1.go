@@ -0,0 +1,337 @@
+package x402go
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/berhberhberh/x402go/registry"
+)
+
+// WireFormat selects how payment requirements and payments are encoded on the wire
+type WireFormat int
+
+const (
+	// WireFormatV1 is the published x402 v1 format: a JSON 402 body with an accepts
+	// array, a base64 X-PAYMENT request header and a base64 X-PAYMENT-RESPONSE header
+	WireFormatV1 WireFormat = iota
+
+	// WireFormatLegacy is the original format: JSON requirements in the X-Payment
+	// response header and a JSON payment in the X-Payment-Response request header
+	WireFormatLegacy
+)
+
+// defaultMaxTimeoutSeconds is advertised when requirements carry no expiry
+const defaultMaxTimeoutSeconds = 60
+
+// NetworkForChain returns the v1 network name for a chain ID or CAIP-2 id, or chain unchanged if it is not known
+func NetworkForChain(chain string) string {
+	if network, ok := registry.LookupNetwork(chain); ok {
+		return network.Name
+	}
+	return chain
+}
+
+// ChainForNetwork returns the chain ID for a v1 network name or CAIP-2 id, or network unchanged if it is not known
+func ChainForNetwork(network string) string {
+	if chainID, ok := registry.EVMChainID(network); ok {
+		return chainID
+	}
+	return network
+}
+
+// PaymentRequirementsV1 is a single entry of the accepts array in a v1 402 response
+type PaymentRequirementsV1 struct {
+	Scheme            string                 `json:"scheme"`
+	Network           string                 `json:"network"`
+	MaxAmountRequired string                 `json:"maxAmountRequired"`
+	Resource          string                 `json:"resource"`
+	Description       string                 `json:"description"`
+	MimeType          string                 `json:"mimeType"`
+	OutputSchema      json.RawMessage        `json:"outputSchema,omitempty"`
+	PayTo             string                 `json:"payTo"`
+	MaxTimeoutSeconds int64                  `json:"maxTimeoutSeconds"`
+	Asset             string                 `json:"asset"`
+	Extra             map[string]interface{} `json:"extra,omitempty"`
+}
+
+// PaymentRequiredResponseV1 is the JSON body of a v1 402 response
+type PaymentRequiredResponseV1 struct {
+	X402Version int                     `json:"x402Version"`
+	Error       string                  `json:"error,omitempty"`
+	Accepts     []PaymentRequirementsV1 `json:"accepts"`
+}
+
+// TransferAuthorization is an EIP-3009 transferWithAuthorization message.
+// All values are decimal or hex strings as they appear on the wire.
+type TransferAuthorization struct {
+	From        string `json:"from"`
+	To          string `json:"to"`
+	Value       string `json:"value"`
+	ValidAfter  string `json:"validAfter"`
+	ValidBefore string `json:"validBefore"`
+	Nonce       string `json:"nonce"`
+}
+
+// ExactPayloadV1 is the scheme-specific payload of an "exact" payment in the v1 format
+type ExactPayloadV1 struct {
+	Signature     string                `json:"signature,omitempty"`
+	Authorization TransferAuthorization `json:"authorization"`
+
+	// TxHash identifies an already submitted transfer for payments made on-chain by the client
+	TxHash string `json:"txHash,omitempty"`
+
+	// Quote echoes the server's signed quote back to x402go servers that issue them
+	Quote string `json:"quote,omitempty"`
+}
+
+// PaymentPayloadV1 is the decoded content of the v1 X-PAYMENT header
+type PaymentPayloadV1 struct {
+	X402Version int            `json:"x402Version"`
+	Scheme      string         `json:"scheme"`
+	Network     string         `json:"network"`
+	Payload     ExactPayloadV1 `json:"payload"`
+}
+
+// SettlementResponseV1 is the decoded content of the v1 X-PAYMENT-RESPONSE header and
+// the response body of a v1 facilitator /settle call
+type SettlementResponseV1 struct {
+	Success     bool   `json:"success"`
+	ErrorReason string `json:"errorReason,omitempty"`
+	Transaction string `json:"transaction"`
+	Network     string `json:"network"`
+	Payer       string `json:"payer,omitempty"`
+}
+
+// FacilitatorRequestV1 is the request body of v1 facilitator /verify and /settle calls
+type FacilitatorRequestV1 struct {
+	X402Version         int                   `json:"x402Version"`
+	PaymentPayload      PaymentPayloadV1      `json:"paymentPayload"`
+	PaymentRequirements PaymentRequirementsV1 `json:"paymentRequirements"`
+
+	// Amount is the amount to charge when settling an "upto" payment (x402go extension)
+	Amount string `json:"amount,omitempty"`
+}
+
+// VerifyResponseV1 is the response body of a v1 facilitator /verify call
+type VerifyResponseV1 struct {
+	IsValid       bool   `json:"isValid"`
+	InvalidReason string `json:"invalidReason,omitempty"`
+	Payer         string `json:"payer,omitempty"`
+}
+
+// EncodeHeaderV1 encodes v as base64 JSON for use in X-PAYMENT and X-PAYMENT-RESPONSE headers
+func EncodeHeaderV1(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// DecodeHeaderV1 decodes a base64 JSON header value into v
+func DecodeHeaderV1(header string, v interface{}) error {
+	data, err := base64.StdEncoding.DecodeString(header)
+	if err != nil {
+		return fmt.Errorf("invalid base64: %w", err)
+	}
+	return json.Unmarshal(data, v)
+}
+
+// ToV1 converts PaymentRequirements to an entry of the v1 accepts array.
+// The nonce and expiry are carried in extra so that x402go clients can echo them back.
+func (pr *PaymentRequirements) ToV1() PaymentRequirementsV1 {
+	extra := make(map[string]interface{}, len(pr.Extra)+2)
+	for k, v := range pr.Extra {
+		extra[k] = v
+	}
+	if pr.Nonce != "" {
+		extra["nonce"] = pr.Nonce
+	}
+	if pr.Pass != nil {
+		extra["pass"] = pr.Pass
+	}
+
+	timeout := int64(defaultMaxTimeoutSeconds)
+	if pr.Expiry != 0 {
+		extra["expiry"] = pr.Expiry
+		if remaining := pr.Expiry - time.Now().Unix(); remaining > 0 {
+			timeout = remaining
+		}
+	}
+
+	if len(extra) == 0 {
+		extra = nil
+	}
+
+	return PaymentRequirementsV1{
+		Scheme:            pr.Scheme,
+		Network:           NetworkForChain(pr.Chain),
+		MaxAmountRequired: pr.Amount,
+		Resource:          pr.Resource,
+		Description:       pr.Description,
+		MimeType:          pr.MimeType,
+		PayTo:             pr.Recipient,
+		MaxTimeoutSeconds: timeout,
+		Asset:             pr.Token,
+		Extra:             extra,
+	}
+}
+
+// RequirementsFromV1 converts an entry of the v1 accepts array to PaymentRequirements
+func RequirementsFromV1(v *PaymentRequirementsV1) *PaymentRequirements {
+	pr := &PaymentRequirements{
+		Scheme:      v.Scheme,
+		Amount:      v.MaxAmountRequired,
+		Token:       v.Asset,
+		Chain:       ChainForNetwork(v.Network),
+		Recipient:   v.PayTo,
+		Resource:    v.Resource,
+		Description: v.Description,
+		MimeType:    v.MimeType,
+	}
+
+	for k, val := range v.Extra {
+		switch k {
+		case "nonce":
+			if s, ok := val.(string); ok {
+				pr.Nonce = s
+				continue
+			}
+		case "expiry":
+			if expiry, ok := extraInt64(val); ok {
+				pr.Expiry = expiry
+				continue
+			}
+		case "pass":
+			if terms, ok := extraPassTerms(val); ok {
+				pr.Pass = terms
+				continue
+			}
+		}
+		if pr.Extra == nil {
+			pr.Extra = make(map[string]interface{})
+		}
+		pr.Extra[k] = val
+	}
+
+	if pr.Expiry == 0 && v.MaxTimeoutSeconds > 0 {
+		pr.Expiry = time.Now().Unix() + v.MaxTimeoutSeconds
+	}
+
+	return pr
+}
+
+// extraInt64 reads an integer from a decoded extra value
+func extraInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return int64(n), true
+	case int64:
+		return n, true
+	case int:
+		return int64(n), true
+	case json.Number:
+		i, err := n.Int64()
+		return i, err == nil
+	case string:
+		i, err := strconv.ParseInt(n, 10, 64)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+// extraPassTerms reads pass terms from a decoded extra value
+func extraPassTerms(v interface{}) (*PassTerms, bool) {
+	if terms, ok := v.(*PassTerms); ok {
+		return terms, true
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	duration, ok := extraInt64(m["duration"])
+	if !ok {
+		return nil, false
+	}
+	scope, _ := m["scope"].(string)
+	return &PassTerms{Duration: duration, Scope: scope}, true
+}
+
+// ToV1 converts a Payment to the payload carried in the v1 X-PAYMENT header
+func (p *Payment) ToV1() *PaymentPayloadV1 {
+	payload := ExactPayloadV1{
+		Signature: p.Signature,
+		TxHash:    p.TxHash,
+		Quote:     p.Quote,
+	}
+	if p.Authorization != nil {
+		payload.Authorization = *p.Authorization
+	} else {
+		payload.Authorization = TransferAuthorization{
+			From:  p.Sender,
+			To:    p.Recipient,
+			Value: p.Amount,
+			Nonce: p.Nonce,
+		}
+	}
+
+	return &PaymentPayloadV1{
+		X402Version: X402Version,
+		Scheme:      p.Scheme,
+		Network:     NetworkForChain(p.Chain),
+		Payload:     payload,
+	}
+}
+
+// PaymentFromV1 converts a v1 payment payload to a Payment. The v1 payload does not name
+// the token, so it is taken from the requirements the payment is made against.
+func PaymentFromV1(v *PaymentPayloadV1, requirements *PaymentRequirements) *Payment {
+	payment := &Payment{
+		Scheme:    v.Scheme,
+		TxHash:    v.Payload.TxHash,
+		Chain:     ChainForNetwork(v.Network),
+		Amount:    v.Payload.Authorization.Value,
+		Sender:    v.Payload.Authorization.From,
+		Recipient: v.Payload.Authorization.To,
+		Nonce:     v.Payload.Authorization.Nonce,
+		Quote:     v.Payload.Quote,
+	}
+	if v.Payload.Signature != "" {
+		auth := v.Payload.Authorization
+		payment.Signature = v.Payload.Signature
+		payment.Authorization = &auth
+	}
+	if requirements != nil {
+		payment.Token = requirements.Token
+	}
+	return payment
+}
+
+// ToV1 converts a SettleResponse to the v1 settlement response for a payment
+func (sr *SettleResponse) ToV1(payment *Payment) *SettlementResponseV1 {
+	return &SettlementResponseV1{
+		Success:     sr.Settled,
+		ErrorReason: sr.Error,
+		Transaction: sr.TxHash,
+		Network:     NetworkForChain(payment.Chain),
+		Payer:       payment.Sender,
+	}
+}
+
+// NewFacilitatorRequestV1 builds the v1 facilitator request for a payment made against requirements
+func NewFacilitatorRequestV1(payment *Payment, requirements *PaymentRequirements) *FacilitatorRequestV1 {
+	return &FacilitatorRequestV1{
+		X402Version:         X402Version,
+		PaymentPayload:      *payment.ToV1(),
+		PaymentRequirements: requirements.ToV1(),
+	}
+}
+
+// Decode converts a v1 facilitator request to the payment and requirements it describes
+func (v *FacilitatorRequestV1) Decode() (*Payment, *PaymentRequirements) {
+	requirements := RequirementsFromV1(&v.PaymentRequirements)
+	return PaymentFromV1(&v.PaymentPayload, requirements), requirements
+}
@@ -0,0 +1,156 @@
+package x402go
+
+import (
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/berhberhberh/x402go/registry"
+)
+
+// Validate checks that requirements are well-formed: a scheme, a known network name or
+// numeric chain, token, recipient and escrow addresses with valid EIP-55 checksums, a
+// base-unit amount, positive pass terms and, if set, an absolute facilitator URL
+func (pr *PaymentRequirements) Validate() error {
+	if pr.Scheme == "" {
+		return fmt.Errorf("payment requirements have no scheme")
+	}
+	if _, ok := registry.EVMChainID(pr.Chain); !ok {
+		return fmt.Errorf("unknown chain %q", pr.Chain)
+	}
+	if _, err := registry.ChecksumAddress(pr.Token); err != nil {
+		return fmt.Errorf("invalid token: %w", err)
+	}
+	if _, err := registry.ChecksumAddress(pr.Recipient); err != nil {
+		return fmt.Errorf("invalid recipient: %w", err)
+	}
+	if _, err := parseBaseUnits(pr.Amount); err != nil {
+		return err
+	}
+	if escrow := authorizationRecipient(pr); escrow != pr.Recipient {
+		if _, err := registry.ChecksumAddress(escrow); err != nil {
+			return fmt.Errorf("invalid escrow: %w", err)
+		}
+	}
+	if pr.Pass != nil {
+		if err := pr.Pass.validate(); err != nil {
+			return err
+		}
+	}
+	if pr.Facilitator != "" {
+		u, err := url.Parse(pr.Facilitator)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid facilitator URL %q", pr.Facilitator)
+		}
+	}
+	return nil
+}
+
+// parseBaseUnits parses an amount of base units as requirements carry it on the wire: a
+// non-negative base-10 integer
+func parseBaseUnits(s string) (*big.Int, error) {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok || n.Sign() < 0 {
+		return nil, fmt.Errorf("invalid amount %q: not a base-10 integer", s)
+	}
+	return n, nil
+}
+
+// Asset returns the CAIP-19 id of the requirements' token
+func (pr *PaymentRequirements) Asset() (registry.AssetID, error) {
+	chainID, ok := registry.EVMChainID(pr.Chain)
+	if !ok {
+		return registry.AssetID{}, fmt.Errorf("unknown chain %q", pr.Chain)
+	}
+	address, err := registry.ChecksumAddress(pr.Token)
+	if err != nil {
+		return registry.AssetID{}, fmt.Errorf("invalid token: %w", err)
+	}
+	return registry.ERC20(chainID, address), nil
+}
+
+// InvalidRequirementsError is returned by Client when a server's payment requirements
+// are malformed or unsafe to pay
+type InvalidRequirementsError struct {
+	// Requirements are the offending requirements
+	Requirements *PaymentRequirements
+
+	// Err says what is wrong with them
+	Err error
+}
+
+// Error implements error
+func (e *InvalidRequirementsError) Error() string {
+	return "refusing to pay invalid payment requirements: " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *InvalidRequirementsError) Unwrap() error {
+	return e.Err
+}
+
+// CheckPayable checks requirements received from a server before paying them: they must
+// be well-formed as Validate checks, use a known scheme, name a token in tokens
+// (DefaultTokens if nil) and not have expired. A signed quote, if present, must decode
+// and agree with the requirements and not have expired; its signature can only be
+// checked by the server. Failures are returned as *InvalidRequirementsError.
+func CheckPayable(requirements *PaymentRequirements, tokens *TokenRegistry) error {
+	if err := checkPayable(requirements, tokens); err != nil {
+		return &InvalidRequirementsError{Requirements: requirements, Err: err}
+	}
+	return nil
+}
+
+func checkPayable(requirements *PaymentRequirements, tokens *TokenRegistry) error {
+	if requirements.Scheme != SchemeExact && requirements.Scheme != SchemeUpTo {
+		return fmt.Errorf("unknown payment scheme %q", requirements.Scheme)
+	}
+	if err := requirements.Validate(); err != nil {
+		return err
+	}
+
+	if tokens == nil {
+		tokens = DefaultTokens
+	}
+	if _, ok := tokens.Lookup(requirements.Chain, requirements.Token); !ok {
+		return fmt.Errorf("unsupported token %s on chain %s", requirements.Token, requirements.Chain)
+	}
+
+	now := time.Now()
+	if requirements.Expiry != 0 && now.Unix() >= requirements.Expiry {
+		return fmt.Errorf("payment requirements expired at %d", requirements.Expiry)
+	}
+
+	if token := QuoteToken(requirements); token != "" {
+		return checkQuote(token, requirements, now)
+	}
+	return nil
+}
+
+// checkQuote checks that a signed quote covers the terms of the requirements carrying it,
+// escrow included. The quote is signed for the server alone, so this only catches
+// requirements that disagree with their own quote, not a forged pair.
+func checkQuote(token string, requirements *PaymentRequirements, now time.Time) error {
+	var quote Quote
+	if err := decodeToken(token, &quote); err != nil {
+		return fmt.Errorf("malformed quote: %w", err)
+	}
+	if now.After(quote.Expiry) {
+		return fmt.Errorf("quote expired at %s", quote.Expiry.Format(time.RFC3339))
+	}
+
+	for _, option := range quote.Options {
+		if option.Nonce == requirements.Nonce &&
+			option.Scheme == requirements.Scheme &&
+			option.Amount == requirements.Amount &&
+			ChainForNetwork(option.Chain) == ChainForNetwork(requirements.Chain) &&
+			strings.EqualFold(option.Token, requirements.Token) &&
+			strings.EqualFold(option.Recipient, requirements.Recipient) &&
+			strings.EqualFold(authorizationRecipient(option), authorizationRecipient(requirements)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("quote does not cover the payment requirements")
+}
@@ -0,0 +1,70 @@
+package x402go
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckPayable(t *testing.T) {
+	const escrow = "0x2222222222222222222222222222222222222222"
+
+	signer, err := NewQuoteSigner([]byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// quoted returns requirements carrying a quote signed for quotedAs
+	quoted := func(requirements, quotedAs *PaymentRequirements) *PaymentRequirements {
+		quotedAs.Nonce = "n1"
+		token, err := signer.Sign(&Quote{Options: []*PaymentRequirements{quotedAs}, Expiry: time.Now().Add(time.Minute)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		requirements.Nonce = "n1"
+		return withQuoteToken(requirements, token)
+	}
+	with := func(change func(*PaymentRequirements)) *PaymentRequirements {
+		requirements := testRequirements()
+		change(requirements)
+		return requirements
+	}
+	upto := func(via string) func(*PaymentRequirements) {
+		return func(pr *PaymentRequirements) {
+			pr.Scheme = SchemeUpTo
+			pr.Extra[escrowExtraKey] = via
+		}
+	}
+
+	tests := []struct {
+		name         string
+		requirements *PaymentRequirements
+		wantErr      bool
+	}{
+		{"valid", testRequirements(), false},
+		{"hex amount", with(func(pr *PaymentRequirements) { pr.Amount = "0x3e8" }), true},
+		{"padded amount", with(func(pr *PaymentRequirements) { pr.Amount = " 1000" }), true},
+		{"negative amount", with(func(pr *PaymentRequirements) { pr.Amount = "-1" }), true},
+		{"unknown scheme", with(func(pr *PaymentRequirements) { pr.Scheme = "stream" }), true},
+		{"unknown token", with(func(pr *PaymentRequirements) { pr.Token = "0x1111111111111111111111111111111111111111" }), true},
+		{"expired", with(func(pr *PaymentRequirements) { pr.Expiry = time.Now().Add(-time.Second).Unix() }), true},
+		{"matching quote", quoted(testRequirements(), testRequirements()), false},
+		{"quote for another amount", quoted(testRequirements(), with(func(pr *PaymentRequirements) { pr.Amount = "1" })), true},
+		{"matching escrow", quoted(with(upto(escrow)), with(upto(escrow))), false},
+		{"quote for another escrow", quoted(with(upto("0x000000000000000000000000000000000000dEaD")), with(upto(escrow))), true},
+		{"malformed quote", withQuoteToken(testRequirements(), "not-a-quote"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckPayable(tt.requirements, nil)
+			var invalid *InvalidRequirementsError
+			if tt.wantErr && !errors.As(err, &invalid) {
+				t.Fatalf("CheckPayable() = %v, want *InvalidRequirementsError", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("CheckPayable() = %v", err)
+			}
+		})
+	}
+}